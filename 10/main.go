@@ -2,11 +2,20 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
 	"log"
 	"os"
 	"slices"
+	"strings"
 	"time"
+
+	"github.com/Shteevee/AoC2023/internal/polygon"
 )
 
 type point struct {
@@ -19,23 +28,36 @@ type state struct {
 	prev point
 }
 
-func parsePipes(scanner *bufio.Scanner) (point, [][]rune) {
+func parsePipes(scanner *bufio.Scanner) (point, [][]rune, error) {
 	pipes := [][]rune{}
 	start := point{}
+	foundStart := false
 	y := 0
 	for scanner.Scan() {
 		pipe := []rune{}
 		for x, s := range scanner.Text() {
 			pipe = append(pipe, s)
 			if s == 'S' {
+				if foundStart {
+					return start, pipes, fmt.Errorf("second S at %d,%d, already found one at %d,%d", x, y, start.x, start.y)
+				}
 				start.x = x
 				start.y = y
+				foundStart = true
 			}
 		}
 		pipes = append(pipes, pipe)
 		y++
 	}
-	return start, pipes
+	if !foundStart {
+		return start, pipes, errors.New("no S in the input")
+	}
+	startPipe, err := inferStartPipe(start, pipes)
+	if err != nil {
+		return start, pipes, err
+	}
+	pipes[start.y][start.x] = startPipe
+	return start, pipes, nil
 }
 
 func nextPoint(prevP point, p point, nextPipe rune) point {
@@ -75,49 +97,122 @@ func nextPoint(prevP point, p point, nextPipe rune) point {
 	}
 }
 
-func createStartPath(start point, pipes [][]rune) state {
-	northPossible := []rune{'|', 'F', '7'}
-	southPossible := []rune{'|', 'L', 'J'}
-	westPossible := []rune{'-', 'L', 'F'}
-	eastPossible := []rune{'-', '7', 'J'}
-	s := state{}
-	// north
-	if start.y-1 >= 0 && slices.Contains[[]rune](northPossible, pipes[start.y-1][start.x]) {
-		s = state{prev: start, curr: point{x: start.x, y: start.y - 1}}
+var (
+	north = point{x: 0, y: -1}
+	south = point{x: 0, y: 1}
+	west  = point{x: -1, y: 0}
+	east  = point{x: 1, y: 0}
+)
+
+var pipeConnections = map[rune][]point{
+	'|': {north, south},
+	'-': {west, east},
+	'L': {north, east},
+	'J': {north, west},
+	'7': {south, west},
+	'F': {south, east},
+}
+
+func connects(pipe rune, dir point) bool {
+	return slices.Contains(pipeConnections[pipe], dir)
+}
+
+func inBounds(p point, pipes [][]rune) bool {
+	return p.y >= 0 && p.y < len(pipes) && p.x >= 0 && p.x < len(pipes[p.y])
+}
+
+// follows the pipes out of start's first connection and checks they come back
+// in through its other one
+func closesLoop(start point, pipes [][]rune) bool {
+	dir := pipeConnections[pipes[start.y][start.x]][0]
+	prev, curr := start, point{x: start.x + dir.x, y: start.y + dir.y}
+	for steps := 0; steps <= len(pipes)*len(pipes[0]); steps++ {
+		if !inBounds(curr, pipes) {
+			return false
+		}
+		cameFrom := point{x: prev.x - curr.x, y: prev.y - curr.y}
+		if curr == start {
+			return connects(pipes[start.y][start.x], cameFrom)
+		}
+		pipe := pipes[curr.y][curr.x]
+		if !connects(pipe, cameFrom) {
+			return false
+		}
+		out := pipeConnections[pipe][0]
+		if out == cameFrom {
+			out = pipeConnections[pipe][1]
+		}
+		prev, curr = curr, point{x: curr.x + out.x, y: curr.y + out.y}
 	}
-	// south
-	if start.y+1 < len(pipes) && slices.Contains[[]rune](southPossible, pipes[start.y+1][start.x]) {
-		s = state{prev: start, curr: point{x: start.x, y: start.y + 1}}
+	return false
+}
+
+// junk pipes can also point into S, so when more than two neighbours connect
+// each possible shape is tried until one closes the loop
+func inferStartPipe(start point, pipes [][]rune) (rune, error) {
+	connected := []point{}
+	for _, dir := range []point{north, south, west, east} {
+		n := point{x: start.x + dir.x, y: start.y + dir.y}
+		if inBounds(n, pipes) && connects(pipes[n.y][n.x], point{x: -dir.x, y: -dir.y}) {
+			connected = append(connected, dir)
+		}
 	}
-	// west
-	if start.x-1 >= 0 && slices.Contains[[]rune](westPossible, pipes[start.y][start.x-1]) {
-		s = state{prev: start, curr: point{x: start.x - 1, y: start.y}}
+	if len(connected) < 2 {
+		return 0, fmt.Errorf("start at %v connects to %d pipes, expected at least 2", start, len(connected))
 	}
-	// east
-	if start.x-1 < len(pipes) && slices.Contains[[]rune](eastPossible, pipes[start.y][start.x+1]) {
-		s = state{prev: start, curr: point{x: start.x + 1, y: start.y}}
+
+	candidates := []rune{}
+	for _, pipe := range []rune{'|', '-', 'L', 'J', '7', 'F'} {
+		dirs := pipeConnections[pipe]
+		if slices.Contains(connected, dirs[0]) && slices.Contains(connected, dirs[1]) {
+			candidates = append(candidates, pipe)
+		}
+	}
+	for _, pipe := range candidates {
+		pipes[start.y][start.x] = pipe
+		closed := closesLoop(start, pipes)
+		pipes[start.y][start.x] = 'S'
+		if closed {
+			return pipe, nil
+		}
 	}
-	return s
+	return 0, fmt.Errorf("none of the start shapes %q close a loop", string(candidates))
+}
+
+func createStartPath(start point, pipes [][]rune) state {
+	dir := pipeConnections[pipes[start.y][start.x]][0]
+	return state{prev: start, curr: point{x: start.x + dir.x, y: start.y + dir.y}}
+}
+
+func isCorner(pipe rune) bool {
+	return pipe != '|' && pipe != '-'
 }
 
-func part1(start point, pipes [][]rune) (int, map[point]bool) {
+// the corners of the loop are collected in order as polygon vertices for part2Pick
+func part1(start point, pipes [][]rune) (int, map[point]bool, []polygon.Point) {
 	steps := 1
 	state := createStartPath(start, pipes)
 	path := map[point]bool{start: true}
+	vertices := []polygon.Point{}
+	if isCorner(pipes[start.y][start.x]) {
+		vertices = append(vertices, polygon.Point{X: start.x, Y: start.y})
+	}
 
-	for pipes[state.curr.y][state.curr.x] != 'S' {
+	for state.curr != start {
 		path[state.curr] = true
+		if isCorner(pipes[state.curr.y][state.curr.x]) {
+			vertices = append(vertices, polygon.Point{X: state.curr.x, Y: state.curr.y})
+		}
 		nextPoint := nextPoint(state.prev, state.curr, pipes[state.curr.y][state.curr.x])
 		state.prev = state.curr
 		state.curr = nextPoint
 		steps++
 	}
-	return steps / 2, path
+	return steps / 2, path, vertices
 }
 
 // uses ray casting to determine if a tile is inside (https://en.wikipedia.org/wiki/Point_in_polygon)
-// (this works with my input because S would be an F, if S was L or J
-// then you would use F and 7 or just replace S if you're not as lazy as me)
+// (relies on S having been replaced with its real pipe by parsePipes)
 func containedMask(path map[point]bool, pipes [][]rune) [][]bool {
 	xPipesMask := make([][]bool, len(pipes))
 	for i := range xPipesMask {
@@ -153,7 +248,95 @@ func part2(path map[point]bool, pipes [][]rune) int {
 	return total
 }
 
+func part2Pick(vertices []polygon.Point, path map[point]bool) int {
+	return polygon.InteriorPoints(vertices, len(path))
+}
+
+var boxDrawing = map[rune]rune{
+	'|': '│',
+	'-': '─',
+	'L': '└',
+	'J': '┘',
+	'7': '┐',
+	'F': '┌',
+}
+
+const (
+	ansiReset = "\033[0m"
+	ansiLoop  = "\033[1;33m"
+	ansiJunk  = "\033[2m"
+	ansiInner = "\033[46m"
+)
+
+func render(w io.Writer, path map[point]bool, pipes [][]rune) error {
+	mask := containedMask(path, pipes)
+	b := strings.Builder{}
+	for y := range pipes {
+		for x, pipe := range pipes[y] {
+			c, ok := boxDrawing[pipe]
+			if !ok {
+				c = ' '
+			}
+			switch {
+			case path[point{x: x, y: y}]:
+				b.WriteString(ansiLoop + string(c) + ansiReset)
+			case mask[y][x]:
+				b.WriteString(ansiInner + ansiJunk + string(c) + ansiReset)
+			case ok:
+				b.WriteString(ansiJunk + string(c) + ansiReset)
+			default:
+				b.WriteRune(c)
+			}
+		}
+		b.WriteRune('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+const tileSize = 5
+
+func renderPNG(w io.Writer, path map[point]bool, pipes [][]rune) error {
+	mask := containedMask(path, pipes)
+	img := image.NewRGBA(image.Rect(0, 0, len(pipes[0])*tileSize, len(pipes)*tileSize))
+	background := color.RGBA{R: 20, G: 20, B: 30, A: 255}
+	inner := color.RGBA{R: 40, G: 110, B: 120, A: 255}
+	loop := color.RGBA{R: 250, G: 200, B: 40, A: 255}
+	junk := color.RGBA{R: 80, G: 80, B: 90, A: 255}
+
+	for y := range pipes {
+		for x, pipe := range pipes[y] {
+			tileColour := background
+			if mask[y][x] {
+				tileColour = inner
+			}
+			for dy := 0; dy < tileSize; dy++ {
+				for dx := 0; dx < tileSize; dx++ {
+					img.Set(x*tileSize+dx, y*tileSize+dy, tileColour)
+				}
+			}
+
+			pipeColour := junk
+			if path[point{x: x, y: y}] {
+				pipeColour = loop
+			}
+			centre := point{x: x*tileSize + tileSize/2, y: y*tileSize + tileSize/2}
+			for _, dir := range pipeConnections[pipe] {
+				for i := 0; i <= tileSize/2; i++ {
+					img.Set(centre.x+dir.x*i, centre.y+dir.y*i, pipeColour)
+				}
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
 func main() {
+	methodFlag := flag.String("method", "both", "enclosed tile algorithm: raycast, pick or both")
+	renderFlag := flag.Bool("render", false, "draw the pipes with the loop and enclosed tiles highlighted")
+	pngFlag := flag.String("png", "", "write a png of the pipes to this file")
+	flag.Parse()
+
 	start := time.Now()
 	file, err := os.Open("input.txt")
 	if err != nil {
@@ -166,13 +349,47 @@ func main() {
 	}()
 
 	scanner := bufio.NewScanner(file)
-	startP, pipes := parsePipes(scanner)
+	startP, pipes, err := parsePipes(scanner)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	result1, path := part1(startP, pipes)
+	result1, path, vertices := part1(startP, pipes)
 	fmt.Println("Part 1 result:", result1)
 
-	result2 := part2(path, pipes)
-	fmt.Println("Part 2 result:", result2)
+	if *renderFlag {
+		if err = render(os.Stdout, path, pipes); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *pngFlag != "" {
+		pngFile, err := os.Create(*pngFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = renderPNG(pngFile, path, pipes); err != nil {
+			log.Fatal(err)
+		}
+		if err = pngFile.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch *methodFlag {
+	case "raycast":
+		fmt.Println("Part 2 result:", part2(path, pipes))
+	case "pick":
+		fmt.Println("Part 2 result:", part2Pick(vertices, path))
+	case "both":
+		result2 := part2(path, pipes)
+		result2Pick := part2Pick(vertices, path)
+		if result2 != result2Pick {
+			log.Fatalf("ray casting found %d enclosed tiles but pick's theorem found %d", result2, result2Pick)
+		}
+		fmt.Println("Part 2 result:", result2)
+	default:
+		log.Fatalf("unknown method %q", *methodFlag)
+	}
 
 	log.Printf("Time taken: %s", time.Since(start))
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+// a loop with 12 tiles around 3 enclosed ones, S replaces one tile of it
+var testLoop = []string{
+	".......",
+	".F---7.",
+	".|...|.",
+	".L---J.",
+	".......",
+}
+
+func withStart(grid []string, x, y int) string {
+	rows := make([]string, len(grid))
+	copy(rows, grid)
+	rows[y] = rows[y][:x] + "S" + rows[y][x+1:]
+	return strings.Join(rows, "\n")
+}
+
+func TestInferStartPipe(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  rune
+	}{
+		{"vertical", withStart(testLoop, 1, 2), '|'},
+		{"horizontal", withStart(testLoop, 3, 1), '-'},
+		{"north east", withStart(testLoop, 1, 3), 'L'},
+		{"north west", withStart(testLoop, 5, 3), 'J'},
+		{"south west", withStart(testLoop, 5, 1), '7'},
+		{"south east", withStart(testLoop, 1, 1), 'F'},
+		{
+			"junk pipes pointing in",
+			withStart([]string{
+				"..|....",
+				"-F---7.",
+				".|...|.",
+				".L---J.",
+				".......",
+			}, 1, 1),
+			'F',
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, pipes, err := parsePipes(bufio.NewScanner(strings.NewReader(tt.input)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pipes[start.y][start.x]; got != tt.want {
+				t.Errorf("start pipe = %q, want %q", got, tt.want)
+			}
+			steps, path, vertices := part1(start, pipes)
+			if steps != 6 {
+				t.Errorf("part1 = %d, want 6", steps)
+			}
+			if enclosed := part2(path, pipes); enclosed != 3 {
+				t.Errorf("part2 = %d, want 3", enclosed)
+			}
+			if enclosed := part2Pick(vertices, path); enclosed != 3 {
+				t.Errorf("part2Pick = %d, want 3", enclosed)
+			}
+		})
+	}
+}
+
+func TestInferStartPipeDeadEnd(t *testing.T) {
+	input := withStart([]string{".....", ".-.-.", "....."}, 2, 1)
+	if _, _, err := parsePipes(bufio.NewScanner(strings.NewReader(input))); err == nil {
+		t.Error("expected an error for a start with no loop")
+	}
+}
+
+func TestParsePipesNeedsOneStart(t *testing.T) {
+	tests := map[string]string{
+		"no start":   strings.Join(testLoop, "\n"),
+		"two starts": ".......\n.S---7.\n.|...|.\n.L---S.\n.......",
+	}
+	for name, input := range tests {
+		if _, _, err := parsePipes(bufio.NewScanner(strings.NewReader(input))); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
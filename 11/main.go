@@ -2,9 +2,15 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -32,7 +38,83 @@ func parseGalaxy(scanner *bufio.Scanner) [][]rune {
 	return galaxy
 }
 
-func findPlanets(galaxy [][]rune, blankSpacing int) []point {
+type expansionRange struct {
+	from   int
+	to     int
+	factor int
+}
+
+type expansion struct {
+	x       int
+	y       int
+	xRanges []expansionRange
+	yRanges []expansionRange
+}
+
+func uniformExpansion(factor int) expansion {
+	return expansion{x: factor, y: factor}
+}
+
+func expansionFactor(ranges []expansionRange, i int, fallback int) int {
+	for _, r := range ranges {
+		if i >= r.from && i <= r.to {
+			return r.factor
+		}
+	}
+	return fallback
+}
+
+// each line is either "<axis> <factor>" or "<axis> <from>-<to> <factor>",
+// ranges are inclusive, in original row/column numbers and win over the axis default.
+// an axis the file never sets keeps its factor from base, which main builds from
+// the -x and -y flags so leaving both out falls back to uniformExpansion(2)
+func parseExpansion(scanner *bufio.Scanner, base expansion) (expansion, error) {
+	exp := base
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 && len(fields) != 3 {
+			return exp, fmt.Errorf("line %d: expected 2 or 3 fields, got %d", lineNo, len(fields))
+		}
+		factor, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil || factor < 1 {
+			return exp, fmt.Errorf("line %d: bad factor %q", lineNo, fields[len(fields)-1])
+		}
+		var r *expansionRange
+		if len(fields) == 3 {
+			from, to, found := strings.Cut(fields[1], "-")
+			fromN, errFrom := strconv.Atoi(from)
+			toN, errTo := strconv.Atoi(to)
+			if !found || errFrom != nil || errTo != nil || fromN > toN {
+				return exp, fmt.Errorf("line %d: bad range %q", lineNo, fields[1])
+			}
+			r = &expansionRange{from: fromN, to: toN, factor: factor}
+		}
+		switch fields[0] {
+		case "x":
+			if r != nil {
+				exp.xRanges = append(exp.xRanges, *r)
+			} else {
+				exp.x = factor
+			}
+		case "y":
+			if r != nil {
+				exp.yRanges = append(exp.yRanges, *r)
+			} else {
+				exp.y = factor
+			}
+		default:
+			return exp, fmt.Errorf("line %d: unknown axis %q", lineNo, fields[0])
+		}
+	}
+	return exp, scanner.Err()
+}
+
+func findPlanets(galaxy [][]rune, exp expansion) []point {
 	xOffset := 0
 	xMapping := make([]int, len(galaxy[0]))
 	for x := range galaxy[0] {
@@ -44,7 +126,7 @@ func findPlanets(galaxy [][]rune, blankSpacing int) []point {
 		}
 		xMapping[x] = x + xOffset
 		if isBlank {
-			xOffset += blankSpacing - 1
+			xOffset += expansionFactor(exp.xRanges, x, exp.x) - 1
 		}
 	}
 
@@ -59,7 +141,7 @@ func findPlanets(galaxy [][]rune, blankSpacing int) []point {
 			}
 		}
 		if isBlank {
-			yOffset += blankSpacing - 1
+			yOffset += expansionFactor(exp.yRanges, y, exp.y) - 1
 		}
 	}
 	return planets
@@ -92,7 +174,7 @@ func manhattenDist(a point, b point) int {
 	return dist
 }
 
-func part1(planets []point) int {
+func sumPairDistsSlow(planets []point) int {
 	pairs := generatePairs(planets)
 
 	total := 0
@@ -102,7 +184,247 @@ func part1(planets []point) int {
 	return total
 }
 
+// once sorted each coord is the larger one in i pairs, so it adds i*coord minus
+// everything before it
+func sumAxisDists(coords []int) int {
+	slices.Sort(coords)
+	total, prefix := 0, 0
+	for i, c := range coords {
+		total += i*c - prefix
+		prefix += c
+	}
+	return total
+}
+
+func part1(planets []point) int {
+	xs := make([]int, len(planets))
+	ys := make([]int, len(planets))
+	for i, p := range planets {
+		xs[i] = p.x
+		ys[i] = p.y
+	}
+	return sumAxisDists(xs) + sumAxisDists(ys)
+}
+
+type metric func(dx, dy int) float64
+
+var metrics = map[string]metric{
+	"manhattan": func(dx, dy int) float64 { return float64(dx + dy) },
+	"chebyshev": func(dx, dy int) float64 { return float64(max(dx, dy)) },
+	"euclidean": func(dx, dy int) float64 { return math.Hypot(float64(dx), float64(dy)) },
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func (m metric) dist(a, b point) float64 {
+	return m(abs(a.x-b.x), abs(a.y-b.y))
+}
+
+type kdNode struct {
+	planet int
+	left   int
+	right  int
+	min    point
+	max    point
+}
+
+// k-d tree over the planets, each node also stores the bounding box of its
+// subtree so searches can prune for both nearest and farthest queries
+type kdTree struct {
+	planets []point
+	nodes   []kdNode
+	root    int
+}
+
+func newKDTree(planets []point) *kdTree {
+	tree := &kdTree{planets: planets}
+	ids := make([]int, len(planets))
+	for i := range ids {
+		ids[i] = i
+	}
+	tree.root = tree.build(ids, 0)
+	return tree
+}
+
+func (t *kdTree) build(ids []int, depth int) int {
+	if len(ids) == 0 {
+		return -1
+	}
+	if depth%2 == 0 {
+		slices.SortFunc(ids, func(a, b int) int { return t.planets[a].x - t.planets[b].x })
+	} else {
+		slices.SortFunc(ids, func(a, b int) int { return t.planets[a].y - t.planets[b].y })
+	}
+	mid := len(ids) / 2
+	node := kdNode{planet: ids[mid], min: t.planets[ids[mid]], max: t.planets[ids[mid]]}
+	node.left = t.build(ids[:mid], depth+1)
+	node.right = t.build(ids[mid+1:], depth+1)
+	for _, child := range []int{node.left, node.right} {
+		if child != -1 {
+			c := t.nodes[child]
+			node.min = point{x: min(node.min.x, c.min.x), y: min(node.min.y, c.min.y)}
+			node.max = point{x: max(node.max.x, c.max.x), y: max(node.max.y, c.max.y)}
+		}
+	}
+	t.nodes = append(t.nodes, node)
+	return len(t.nodes) - 1
+}
+
+type neighbour struct {
+	planet int
+	dist   float64
+}
+
+// finds the k closest (or farthest) planets to planets[from], excluding itself
+func (t *kdTree) search(from int, k int, m metric, farthest bool) []neighbour {
+	p := t.planets[from]
+	better := func(a, b float64) bool { return a < b }
+	boxDist := func(n kdNode) float64 {
+		return m(max(0, n.min.x-p.x, p.x-n.max.x), max(0, n.min.y-p.y, p.y-n.max.y))
+	}
+	if farthest {
+		better = func(a, b float64) bool { return a > b }
+		boxDist = func(n kdNode) float64 {
+			return m(max(abs(p.x-n.min.x), abs(p.x-n.max.x)), max(abs(p.y-n.min.y), abs(p.y-n.max.y)))
+		}
+	}
+
+	results := []neighbour{}
+	var visit func(i int)
+	visit = func(i int) {
+		if i == -1 {
+			return
+		}
+		node := t.nodes[i]
+		if len(results) == k && !better(boxDist(node), results[k-1].dist) {
+			return
+		}
+		if node.planet != from {
+			n := neighbour{planet: node.planet, dist: m.dist(p, t.planets[node.planet])}
+			pos, _ := slices.BinarySearchFunc(results, n, func(a, b neighbour) int {
+				if better(a.dist, b.dist) {
+					return -1
+				}
+				if better(b.dist, a.dist) {
+					return 1
+				}
+				return 0
+			})
+			if pos < k {
+				results = slices.Insert(results, pos, n)
+				if len(results) > k {
+					results = results[:k]
+				}
+			}
+		}
+		left, right := node.left, node.right
+		if right != -1 && (left == -1 || better(boxDist(t.nodes[right]), boxDist(t.nodes[left]))) {
+			left, right = right, left
+		}
+		visit(left)
+		visit(right)
+	}
+	visit(t.root)
+	return results
+}
+
+// returns the indices of the closest (or farthest) two planets and their distance
+func (t *kdTree) extremePair(m metric, farthest bool) (int, int, float64) {
+	bestA, bestB := -1, -1
+	bestDist := math.Inf(1)
+	if farthest {
+		bestDist = math.Inf(-1)
+	}
+	for i := range t.planets {
+		found := t.search(i, 1, m, farthest)
+		if len(found) == 0 {
+			continue
+		}
+		if (!farthest && found[0].dist < bestDist) || (farthest && found[0].dist > bestDist) {
+			bestDist = found[0].dist
+			bestA, bestB = i, found[0].planet
+		}
+	}
+	return bestA, bestB, bestDist
+}
+
+func galaxyArg(arg string, planets []point) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(planets) {
+		return 0, fmt.Errorf("galaxy %q should be a number from 1 to %d", arg, len(planets))
+	}
+	return n - 1, nil
+}
+
+// query dist <a> <b> | query nearest <galaxy> <k> | query closest | query farthest
+func runQuery(args []string, planets []point, m metric) error {
+	if len(args) == 0 {
+		return errors.New("query needs one of dist, nearest, closest or farthest")
+	}
+	tree := newKDTree(planets)
+	switch args[0] {
+	case "dist":
+		if len(args) != 3 {
+			return errors.New("usage: query dist <galaxy> <galaxy>")
+		}
+		a, err := galaxyArg(args[1], planets)
+		if err != nil {
+			return err
+		}
+		b, err := galaxyArg(args[2], planets)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Distance from %d to %d: %g\n", a+1, b+1, m.dist(planets[a], planets[b]))
+	case "nearest":
+		if len(args) != 3 {
+			return errors.New("usage: query nearest <galaxy> <k>")
+		}
+		from, err := galaxyArg(args[1], planets)
+		if err != nil {
+			return err
+		}
+		k, err := strconv.Atoi(args[2])
+		if err != nil || k < 1 {
+			return fmt.Errorf("k %q should be a positive number", args[2])
+		}
+		for _, n := range tree.search(from, k, m, false) {
+			fmt.Printf("Galaxy %d: %g\n", n.planet+1, n.dist)
+		}
+	case "closest", "farthest":
+		if len(planets) < 2 {
+			return errors.New("need at least two galaxies")
+		}
+		a, b, dist := tree.extremePair(m, args[0] == "farthest")
+		fmt.Printf("%s pair: galaxy %d and %d at %g\n", args[0], min(a, b)+1, max(a, b)+1, dist)
+	default:
+		return fmt.Errorf("unknown query %q", args[0])
+	}
+	return nil
+}
+
 func main() {
+	configFlag := flag.String("config", "", "expansion config file, see parseExpansion")
+	xFlag := flag.Int("x", 2, "factor each empty column expands by for the custom result")
+	yFlag := flag.Int("y", 2, "factor each empty row expands by for the custom result")
+	coordsFlag := flag.Bool("coords", false, "print the expanded coordinates of each galaxy")
+	metricFlag := flag.String("metric", "manhattan", "distance metric for queries: manhattan, chebyshev or euclidean")
+	flag.Parse()
+	if *xFlag < 1 || *yFlag < 1 {
+		log.Fatalf("expansion factors must be at least 1, got -x %d -y %d", *xFlag, *yFlag)
+	}
+	custom := *configFlag != ""
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "x" || f.Name == "y" {
+			custom = true
+		}
+	})
+
 	start := time.Now()
 	file, err := os.Open("input.txt")
 	if err != nil {
@@ -116,14 +438,49 @@ func main() {
 
 	scanner := bufio.NewScanner(file)
 	galaxy := parseGalaxy(scanner)
-	planets := findPlanets(galaxy, 2)
+	planets := findPlanets(galaxy, uniformExpansion(2))
 
 	result1 := part1(planets)
 	fmt.Println("Part 1 result:", result1)
 
-	oldPlanets := findPlanets(galaxy, 1000000)
+	oldPlanets := findPlanets(galaxy, uniformExpansion(1000000))
 	result2 := part1(oldPlanets)
 	fmt.Println("Part 2 result:", result2)
 
+	if custom {
+		exp := expansion{x: *xFlag, y: *yFlag}
+		if *configFlag != "" {
+			configFile, err := os.Open(*configFlag)
+			if err != nil {
+				log.Fatal(err)
+			}
+			exp, err = parseExpansion(bufio.NewScanner(configFile), exp)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err = configFile.Close(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		planets = findPlanets(galaxy, exp)
+		fmt.Println("Custom expansion result:", part1(planets))
+	}
+
+	if *coordsFlag {
+		for i, p := range planets {
+			fmt.Printf("Galaxy %d: %d,%d\n", i+1, p.x, p.y)
+		}
+	}
+
+	if flag.Arg(0) == "query" {
+		m, ok := metrics[*metricFlag]
+		if !ok {
+			log.Fatalf("unknown metric %q", *metricFlag)
+		}
+		if err = runQuery(flag.Args()[1:], planets, m); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	log.Printf("Time taken: %s", time.Since(start))
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func randomPlanets(r *rand.Rand, n int, size int) []point {
+	planets := make([]point, n)
+	for i := range planets {
+		planets[i] = point{x: r.IntN(size), y: r.IntN(size)}
+	}
+	return planets
+}
+
+func TestPart1MatchesAllPairs(t *testing.T) {
+	r := rand.New(rand.NewPCG(11, 2023))
+	for i := 0; i < 100; i++ {
+		planets := randomPlanets(r, r.IntN(60), 1+r.IntN(1000))
+		want := sumPairDistsSlow(planets)
+		if got := part1(planets); got != want {
+			t.Fatalf("part1 = %d, all pairs = %d for %v", got, want, planets)
+		}
+	}
+}
+
+func BenchmarkPart1(b *testing.B) {
+	planets := randomPlanets(rand.New(rand.NewPCG(11, 2023)), 2000, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		part1(planets)
+	}
+}
+
+func BenchmarkSumPairDistsSlow(b *testing.B) {
+	planets := randomPlanets(rand.New(rand.NewPCG(11, 2023)), 2000, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sumPairDistsSlow(planets)
+	}
+}
+
+func TestExtremePairMatchesAllPairs(t *testing.T) {
+	r := rand.New(rand.NewPCG(34, 2023))
+	for i := 0; i < 50; i++ {
+		planets := randomPlanets(r, 2+r.IntN(40), 1+r.IntN(100))
+		tree := newKDTree(planets)
+		for name, m := range metrics {
+			for _, farthest := range []bool{false, true} {
+				want := m.dist(planets[0], planets[1])
+				for a := range planets {
+					for b := a + 1; b < len(planets); b++ {
+						d := m.dist(planets[a], planets[b])
+						if (!farthest && d < want) || (farthest && d > want) {
+							want = d
+						}
+					}
+				}
+				a, b, got := tree.extremePair(m, farthest)
+				if got != want || m.dist(planets[a], planets[b]) != got {
+					t.Fatalf("%s farthest=%v: galaxies %d and %d at %g, want %g", name, farthest, a+1, b+1, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestParseExpansionKeepsBaseForMissingAxis(t *testing.T) {
+	config := "# only rows\ny 10\ny 3-4 5\n"
+	exp, err := parseExpansion(bufio.NewScanner(strings.NewReader(config)), uniformExpansion(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.x != 2 || exp.y != 10 {
+		t.Errorf("got x %d y %d, want x 2 y 10", exp.x, exp.y)
+	}
+	if got := expansionFactor(exp.yRanges, 3, exp.y); got != 5 {
+		t.Errorf("row 3 factor = %d, want 5", got)
+	}
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"log"
+	"math/big"
+	"math/rand/v2"
 	"os"
+	"runtime"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -92,16 +97,325 @@ func calcSpringArrangements(layout []rune, conditions []int, cache map[string]in
 	}
 }
 
-func part1(springs []spring) int {
-	total := 0
-	cache := map[string]int{}
-	for _, spring := range springs {
-		total += calcSpringArrangements(spring.layout, spring.conditions, cache)
+type arrangementTable struct {
+	layout     []rune
+	conditions []int
+	// how many springs from i onwards could be damaged
+	damageRun []int
+	// counts[i][j] is the number of ways layout[i:] can satisfy conditions[j:]
+	counts [][]int
+}
+
+func newArrangementShape(layout []rune, conditions []int) arrangementTable {
+	n := len(layout)
+	t := arrangementTable{layout: layout, conditions: conditions, damageRun: make([]int, n+1)}
+	for i := n - 1; i >= 0; i-- {
+		if layout[i] != '.' {
+			t.damageRun[i] = t.damageRun[i+1] + 1
+		}
+	}
+	return t
+}
+
+func newArrangementTable(layout []rune, conditions []int) arrangementTable {
+	n, m := len(layout), len(conditions)
+	t := newArrangementShape(layout, conditions)
+	t.counts = make([][]int, n+1)
+	for i := range t.counts {
+		t.counts[i] = make([]int, m+1)
+	}
+	t.counts[n][m] = 1
+	for i := n - 1; i >= 0; i-- {
+		for j := m; j >= 0; j-- {
+			if layout[i] != '#' {
+				t.counts[i][j] += t.counts[i+1][j]
+			}
+			if next, ok := t.groupEnd(i, j); ok {
+				t.counts[i][j] += t.counts[next][j+1]
+			}
+		}
+	}
+	return t
+}
+
+// where the next group would start if conditions[j] is placed at layout[i]
+func (t arrangementTable) groupEnd(i int, j int) (int, bool) {
+	if j == len(t.conditions) || t.damageRun[i] < t.conditions[j] {
+		return 0, false
+	}
+	end := i + t.conditions[j]
+	if end == len(t.layout) {
+		return end, true
+	}
+	if t.layout[end] == '#' {
+		return 0, false
+	}
+	return end + 1, true
+}
+
+func countArrangements(layout []rune, conditions []int) int {
+	return newArrangementTable(layout, conditions).counts[0][0]
+}
+
+// same as countArrangements for counts that outgrow int
+func countArrangementsBig(layout []rune, conditions []int) *big.Int {
+	n, m := len(layout), len(conditions)
+	t := newArrangementShape(layout, conditions)
+	counts := make([][]*big.Int, n+1)
+	for i := range counts {
+		counts[i] = make([]*big.Int, m+1)
+		for j := range counts[i] {
+			counts[i][j] = new(big.Int)
+		}
+	}
+	counts[n][m].SetInt64(1)
+	for i := n - 1; i >= 0; i-- {
+		for j := m; j >= 0; j-- {
+			if layout[i] != '#' {
+				counts[i][j].Add(counts[i][j], counts[i+1][j])
+			}
+			if next, ok := t.groupEnd(i, j); ok {
+				counts[i][j].Add(counts[i][j], counts[next][j+1])
+			}
+		}
+	}
+	return counts[0][0]
+}
+
+// marks the group for conditions[j] at out[i] as damaged along with the
+// operational spring separating it from the next group
+func (t arrangementTable) fillGroup(out []rune, i int, j int, next int) {
+	end := i + t.conditions[j]
+	for k := i; k < end; k++ {
+		out[k] = '#'
+	}
+	if next > end {
+		out[end] = '.'
+	}
+}
+
+func (t arrangementTable) enumerate(yield func([]rune) bool) {
+	out := slices.Clone(t.layout)
+	var walk func(i int, j int) bool
+	walk = func(i int, j int) bool {
+		if i == len(t.layout) {
+			return yield(slices.Clone(out))
+		}
+		if t.layout[i] != '#' && t.counts[i+1][j] > 0 {
+			out[i] = '.'
+			if !walk(i+1, j) {
+				return false
+			}
+		}
+		if next, ok := t.groupEnd(i, j); ok && t.counts[next][j+1] > 0 {
+			saved := slices.Clone(out[i:next])
+			t.fillGroup(out, i, j, next)
+			if !walk(next, j+1) {
+				return false
+			}
+			copy(out[i:next], saved)
+		}
+		out[i] = t.layout[i]
+		return true
+	}
+	if t.counts[0][0] > 0 {
+		walk(0, 0)
+	}
+}
+
+// picks one arrangement uniformly at random by weighting each choice by its count
+func (t arrangementTable) sample(r *rand.Rand) ([]rune, bool) {
+	if t.counts[0][0] == 0 {
+		return nil, false
+	}
+	out := slices.Clone(t.layout)
+	i, j := 0, 0
+	for i < len(t.layout) {
+		operational := 0
+		if t.layout[i] != '#' {
+			operational = t.counts[i+1][j]
+		}
+		if r.IntN(t.counts[i][j]) < operational {
+			out[i] = '.'
+			i++
+			continue
+		}
+		next, _ := t.groupEnd(i, j)
+		t.fillGroup(out, i, j, next)
+		i, j = next, j+1
+	}
+	return out, true
+}
+
+func validArrangement(layout []rune, arrangement []rune, conditions []int) bool {
+	if len(layout) != len(arrangement) {
+		return false
+	}
+	groups := []int{}
+	run := 0
+	for i, c := range arrangement {
+		if c != '#' && c != '.' || layout[i] != '?' && layout[i] != c {
+			return false
+		}
+		if c == '#' {
+			run++
+		} else if run > 0 {
+			groups = append(groups, run)
+			run = 0
+		}
+	}
+	if run > 0 {
+		groups = append(groups, run)
+	}
+	return slices.Equal(groups, conditions)
+}
+
+func printArrangements(s spring, limit int, samples int) {
+	t := newArrangementTable(s.layout, s.conditions)
+	fmt.Printf("%s %v has %d arrangements\n", string(s.layout), s.conditions, t.counts[0][0])
+	show := func(arrangement []rune) {
+		if !validArrangement(s.layout, arrangement, s.conditions) {
+			log.Fatalf("generated invalid arrangement %s", string(arrangement))
+		}
+		fmt.Println(string(arrangement))
+	}
+	if samples > 0 {
+		r := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+		for k := 0; k < samples; k++ {
+			if arrangement, ok := t.sample(r); ok {
+				show(arrangement)
+			}
+		}
+		return
+	}
+	shown := 0
+	for arrangement := range t.enumerate {
+		if limit > 0 && shown == limit {
+			break
+		}
+		show(arrangement)
+		shown++
+	}
+}
+
+type springResult struct {
+	arrangements *big.Int
+	elapsed      time.Duration
+}
+
+func countAllArrangements(springs []spring, count func([]rune, []int) *big.Int) []springResult {
+	results := make([]springResult, len(springs))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				start := time.Now()
+				arrangements := count(springs[i].layout, springs[i].conditions)
+				results[i] = springResult{arrangements: arrangements, elapsed: time.Since(start)}
+			}
+		}()
+	}
+	for i := range springs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func sumArrangements(springs []spring, count func([]rune, []int) *big.Int, timings bool) *big.Int {
+	total := new(big.Int)
+	for i, result := range countAllArrangements(springs, count) {
+		if timings {
+			fmt.Printf("Spring %d: %s arrangements in %s\n", i+1, result.arrangements, result.elapsed)
+		}
+		total.Add(total, result.arrangements)
 	}
 	return total
 }
 
+// a single copy of a spring is far too short to overflow, so part 1 sticks to
+// the int table
+func part1(springs []spring, timings bool) *big.Int {
+	return sumArrangements(springs, func(layout []rune, conditions []int) *big.Int {
+		return big.NewInt(int64(countArrangements(layout, conditions)))
+	}, timings)
+}
+
+// unfolded counts grow roughly geometrically with folds so they are kept as big ints
+func part2(springs []spring, timings bool) *big.Int {
+	return sumArrangements(springs, countArrangementsBig, timings)
+}
+
+// each spring gets its own cache so memory stays bounded by the longest spring
+func verifyArrangements(springs []spring) {
+	for i, spring := range springs {
+		want := calcSpringArrangements(spring.layout, spring.conditions, map[string]int{})
+		got := countArrangements(spring.layout, spring.conditions)
+		if want != got {
+			log.Fatalf("spring %d: dp found %d arrangements but recursion found %d", i+1, got, want)
+		}
+	}
+}
+
+func unfold(s spring, folds int, separator rune) spring {
+	unfolded := spring{}
+	for i := 0; i < folds; i++ {
+		if i > 0 {
+			unfolded.layout = append(unfolded.layout, separator)
+		}
+		unfolded.layout = append(unfolded.layout, s.layout...)
+		unfolded.conditions = append(unfolded.conditions, s.conditions...)
+	}
+	return unfolded
+}
+
+func unfoldAll(springs []spring, folds int, separator rune) []spring {
+	unfolded := []spring{}
+	for _, s := range springs {
+		unfolded = append(unfolded, unfold(s, folds, separator))
+	}
+	return unfolded
+}
+
+func printGrowth(springs []spring, maxFolds int, separator rune) {
+	prev := new(big.Int)
+	for folds := 1; folds <= maxFolds; folds++ {
+		total := new(big.Int)
+		for _, s := range unfoldAll(springs, folds, separator) {
+			total.Add(total, countArrangementsBig(s.layout, s.conditions))
+		}
+		if prev.Sign() == 0 {
+			fmt.Printf("Folds %d: %s\n", folds, total)
+		} else {
+			ratio, _ := new(big.Rat).SetFrac(total, prev).Float64()
+			fmt.Printf("Folds %d: %s (x%.4f)\n", folds, total, ratio)
+		}
+		prev = total
+	}
+}
+
 func main() {
+	timingsFlag := flag.Bool("timings", false, "print the arrangement count and time taken for each spring")
+	verifyFlag := flag.Bool("verify", false, "check the dp against the memoised recursion")
+	enumerateFlag := flag.Int("enumerate", 0, "print the arrangements of this spring (numbered from 1) instead of solving")
+	limitFlag := flag.Int("limit", 0, "maximum number of arrangements to print, 0 for all")
+	sampleFlag := flag.Int("sample", 0, "print this many uniformly random arrangements instead of enumerating")
+	foldsFlag := flag.Int("folds", 5, "how many copies of each spring to unfold for part 2")
+	separatorFlag := flag.String("separator", "?", "spring placed between unfolded copies")
+	growthFlag := flag.Int("growth", 0, "print the total arrangements for every fold count up to this one instead of solving")
+	flag.Parse()
+	if *foldsFlag < 1 {
+		log.Fatalf("folds should be at least 1, got %d", *foldsFlag)
+	}
+	separator := []rune(*separatorFlag)
+	if len(separator) != 1 || !strings.ContainsRune(".#?", separator[0]) {
+		log.Fatalf("separator %q should be one of . # ?", *separatorFlag)
+	}
+
 	start := time.Now()
 	file, err := os.Open("input.txt")
 	if err != nil {
@@ -115,21 +429,30 @@ func main() {
 	scanner := bufio.NewScanner(file)
 	springs := parseSprings(scanner)
 
-	result1 := part1(springs)
+	if *enumerateFlag > 0 {
+		if *enumerateFlag > len(springs) {
+			log.Fatalf("only %d springs", len(springs))
+		}
+		printArrangements(springs[*enumerateFlag-1], *limitFlag, *sampleFlag)
+		return
+	}
+
+	if *growthFlag > 0 {
+		printGrowth(springs, *growthFlag, separator[0])
+		return
+	}
+
+	if *verifyFlag {
+		verifyArrangements(springs)
+	}
+	result1 := part1(springs, *timingsFlag)
 	fmt.Println("Part 1 result:", result1)
 
-	for i := range springs {
-		layout := slices.Clone(springs[i].layout)
-		conditions := slices.Clone(springs[i].conditions)
-		for j := 0; j < 4; j++ {
-			layout = append(layout, '?')
-			layout = append(layout, springs[i].layout...)
-			conditions = append(conditions, springs[i].conditions...)
-		}
-		springs[i].layout = layout
-		springs[i].conditions = conditions
+	springs = unfoldAll(springs, *foldsFlag, separator[0])
+	if *verifyFlag {
+		verifyArrangements(springs)
 	}
-	result2 := part1(springs)
+	result2 := part2(springs, *timingsFlag)
 	fmt.Println("Part 2 result:", result2)
 
 	log.Printf("Time taken: %s", time.Since(start))
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+const example = `???.### 1,1,3
+.??..??...?##. 1,1,3
+?#?#?#?#?#?#?#? 1,3,1,6
+????.#...#... 4,1,1
+????.######..#####. 1,6,5
+?###???????? 3,2,1`
+
+func TestExample(t *testing.T) {
+	springs := parseSprings(bufio.NewScanner(strings.NewReader(example)))
+	if got := part1(springs, false); got.Cmp(big.NewInt(21)) != 0 {
+		t.Errorf("part1 = %s, want 21", got)
+	}
+	if got := part2(unfoldAll(springs, 5, '?'), false); got.Cmp(big.NewInt(525152)) != 0 {
+		t.Errorf("part2 = %s, want 525152", got)
+	}
+}
+
+// single damaged springs in a run of ? have binomially many arrangements, so
+// unfolding 20 times passes what an int can hold
+func TestUnfoldedCountsOutgrowInt(t *testing.T) {
+	s := spring{layout: []rune("????????????????????"), conditions: []int{1, 1, 1}}
+	small := unfold(s, 2, '?')
+	want := big.NewInt(int64(countArrangements(small.layout, small.conditions)))
+	if got := part2([]spring{small}, false); got.Cmp(want) != 0 {
+		t.Errorf("part2 = %s, int table gives %s", got, want)
+	}
+	if got := part2([]spring{unfold(s, 20, '?')}, false); got.IsInt64() {
+		t.Errorf("part2 = %s, expected a count past int64", got)
+	}
+}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math/bits"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -39,48 +44,232 @@ func transpose[T any](a [][]T) [][]T {
 	return t
 }
 
-func findReflectionIndex(pattern pattern, targetDiff int) int {
+type position struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type candidate struct {
+	Axis  string `json:"axis"`
+	Line  int    `json:"line"`
+	Diffs int    `json:"diffs"`
+	// the first mismatched cell, which is the smudge when there is only one
+	FirstDiff *position `json:"firstDiff,omitempty"`
+}
+
+type reflectionReport struct {
+	Pattern    int         `json:"pattern"`
+	TargetDiff int         `json:"targetDiff"`
+	Axis       string      `json:"axis,omitempty"`
+	Line       int         `json:"line,omitempty"`
+	Score      int         `json:"score"`
+	Smudge     *position   `json:"smudge,omitempty"`
+	Candidates []candidate `json:"candidates"`
+	Warning    string      `json:"warning,omitempty"`
+}
+
+func findReflectionCandidates(pattern pattern, axis string) []candidate {
+	candidates := []candidate{}
 	for i := 1; i < len(pattern); i++ {
-		diffs := 0
+		c := candidate{Axis: axis, Line: i}
 		for j := 0; j < i && j < len(pattern)-i; j++ {
 			for k := 0; k < len(pattern[i-j-1]); k++ {
 				if pattern[i-j-1][k] != pattern[i+j][k] {
-					diffs++
+					if c.Diffs == 0 {
+						c.FirstDiff = &position{X: k, Y: i - j - 1}
+						if axis == "column" {
+							c.FirstDiff = &position{X: i - j - 1, Y: k}
+						}
+					}
+					c.Diffs++
 				}
 			}
 		}
-		if diffs == targetDiff {
-			return i
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+func matchingCandidates(candidates []candidate, targetDiff int) []candidate {
+	matches := []candidate{}
+	for _, c := range candidates {
+		if c.Diffs == targetDiff {
+			matches = append(matches, c)
 		}
 	}
-	return 0
+	return matches
+}
+
+func reflectionWarning(matches int) string {
+	switch {
+	case matches == 0:
+		return "no reflection found"
+	case matches > 1:
+		return fmt.Sprintf("%d reflections found, scoring the first", matches)
+	}
+	return ""
+}
+
+func candidateScore(c candidate) int {
+	if c.Axis == "row" {
+		return c.Line * 100
+	}
+	return c.Line
+}
+
+func findReflection(pattern pattern, targetDiff int) reflectionReport {
+	report := reflectionReport{TargetDiff: targetDiff}
+	report.Candidates = append(
+		findReflectionCandidates(pattern, "row"),
+		findReflectionCandidates(transpose(pattern), "column")...,
+	)
+
+	matches := matchingCandidates(report.Candidates, targetDiff)
+	report.Warning = reflectionWarning(len(matches))
+	if len(matches) == 0 {
+		return report
+	}
+
+	report.Axis = matches[0].Axis
+	report.Line = matches[0].Line
+	report.Score = candidateScore(matches[0])
+	if targetDiff == 1 {
+		report.Smudge = matches[0].FirstDiff
+	}
+	return report
 }
 
 func findReflectionValue(pattern pattern, targetDiff int) int {
-	yReflection := findReflectionIndex(pattern, targetDiff)
-	if yReflection != 0 {
-		return yReflection * 100
+	return findReflection(pattern, targetDiff).Score
+}
+
+func reportReflections(patterns []pattern, targetDiff int) []reflectionReport {
+	reports := []reflectionReport{}
+	for i, pattern := range patterns {
+		report := findReflection(pattern, targetDiff)
+		report.Pattern = i + 1
+		if report.Warning != "" {
+			log.Printf("pattern %d: %s", report.Pattern, report.Warning)
+		}
+		reports = append(reports, report)
 	}
-	return findReflectionIndex(transpose(pattern), targetDiff)
+	return reports
 }
 
-func part1(patterns []pattern) int {
-	total := 0
+func printReports(w io.Writer, reports []reflectionReport) error {
+	b := strings.Builder{}
+	for _, r := range reports {
+		fmt.Fprintf(&b, "Pattern %d (target diff %d): ", r.Pattern, r.TargetDiff)
+		if r.Axis == "" {
+			b.WriteString("no reflection")
+		} else {
+			fmt.Fprintf(&b, "%s %d, score %d", r.Axis, r.Line, r.Score)
+		}
+		if r.Smudge != nil {
+			fmt.Fprintf(&b, ", smudge at %d,%d", r.Smudge.X, r.Smudge.Y)
+		}
+		if r.Warning != "" {
+			fmt.Fprintf(&b, " (warning: %s)", r.Warning)
+		}
+		b.WriteRune('\n')
+		for _, c := range r.Candidates {
+			fmt.Fprintf(&b, "  %s %d: %d diffs\n", c.Axis, c.Line, c.Diffs)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// one bit per cell, set for rocks, with bit x of rows[y] matching bit y of cols[x].
+// patterns with a side over 64 cells don't fit and keep their runes instead
+type bitPattern struct {
+	rows  []uint64
+	cols  []uint64
+	runes pattern
+}
+
+func encodePattern(pattern pattern) bitPattern {
+	if len(pattern) > 64 || len(pattern[0]) > 64 {
+		return bitPattern{runes: pattern}
+	}
+	bp := bitPattern{rows: make([]uint64, len(pattern)), cols: make([]uint64, len(pattern[0]))}
+	for y := range pattern {
+		for x, c := range pattern[y] {
+			if c == '#' {
+				bp.rows[y] |= 1 << x
+				bp.cols[x] |= 1 << y
+			}
+		}
+	}
+	return bp
+}
+
+func encodePatterns(patterns []pattern) []bitPattern {
+	bps := []bitPattern{}
 	for _, pattern := range patterns {
-		total += findReflectionValue(pattern, 0)
+		bps = append(bps, encodePattern(pattern))
 	}
-	return total
+	return bps
 }
 
-func part2(patterns []pattern) int {
+func findMirrorLines(lines []uint64, targetDiff int) []int {
+	found := []int{}
+	for i := 1; i < len(lines); i++ {
+		diffs := 0
+		for j := 0; j < i && j < len(lines)-i && diffs <= targetDiff; j++ {
+			diffs += bits.OnesCount64(lines[i-j-1] ^ lines[i+j])
+		}
+		if diffs == targetDiff {
+			found = append(found, i)
+		}
+	}
+	return found
+}
+
+// every row then column line that reflects with exactly targetDiff cells changed
+func (bp bitPattern) reflections(targetDiff int) []candidate {
+	if bp.runes != nil {
+		return matchingCandidates(append(
+			findReflectionCandidates(bp.runes, "row"),
+			findReflectionCandidates(transpose(bp.runes), "column")...,
+		), targetDiff)
+	}
+	matches := []candidate{}
+	for _, line := range findMirrorLines(bp.rows, targetDiff) {
+		matches = append(matches, candidate{Axis: "row", Line: line, Diffs: targetDiff})
+	}
+	for _, line := range findMirrorLines(bp.cols, targetDiff) {
+		matches = append(matches, candidate{Axis: "column", Line: line, Diffs: targetDiff})
+	}
+	return matches
+}
+
+func sumReflectionValues(bps []bitPattern, targetDiff int) int {
 	total := 0
-	for _, pattern := range patterns {
-		total += findReflectionValue(pattern, 1)
+	for i, bp := range bps {
+		matches := bp.reflections(targetDiff)
+		if warning := reflectionWarning(len(matches)); warning != "" {
+			log.Printf("pattern %d: %s", i+1, warning)
+		}
+		if len(matches) != 0 {
+			total += candidateScore(matches[0])
+		}
 	}
 	return total
 }
 
+func part1(bps []bitPattern) int {
+	return sumReflectionValues(bps, 0)
+}
+
+func part2(bps []bitPattern) int {
+	return sumReflectionValues(bps, 1)
+}
+
 func main() {
+	reportFlag := flag.String("report", "", "print a reflection report for every pattern as text or json instead of solving")
+	flag.Parse()
+
 	start := time.Now()
 	file, err := os.Open("input.txt")
 	if err != nil {
@@ -94,10 +283,30 @@ func main() {
 	scanner := bufio.NewScanner(file)
 	patterns := parsePatterns(scanner)
 
-	result1 := part1(patterns)
+	if *reportFlag != "" {
+		reports := append(reportReflections(patterns, 0), reportReflections(patterns, 1)...)
+		switch *reportFlag {
+		case "text":
+			err = printReports(os.Stdout, reports)
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(reports)
+		default:
+			err = fmt.Errorf("unknown report format %q", *reportFlag)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	bps := encodePatterns(patterns)
+
+	result1 := part1(bps)
 	fmt.Println("Part 1 result:", result1)
 
-	result2 := part2(patterns)
+	result2 := part2(bps)
 	fmt.Println("Part 2 result:", result2)
 
 	log.Printf("Time taken: %s", time.Since(start))
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// random patterns mostly have no reflection, so each one is mirrored about
+// a random row to give the search something to find
+func randomPatterns(r *rand.Rand, n int, minSize int, maxSize int) []pattern {
+	patterns := []pattern{}
+	for i := 0; i < n; i++ {
+		height := minSize + r.IntN(maxSize-minSize+1)
+		width := minSize + r.IntN(maxSize-minSize+1)
+		pattern := make(pattern, height)
+		line := 1 + r.IntN(height-1)
+		for y := range pattern {
+			mirror := 2*line - y - 1
+			if y >= line && mirror >= 0 {
+				pattern[y] = append([]rune{}, pattern[mirror]...)
+				continue
+			}
+			for x := 0; x < width; x++ {
+				pattern[y] = append(pattern[y], []rune(".#")[r.IntN(2)])
+			}
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+func TestBitmasksMatchRunes(t *testing.T) {
+	r := rand.New(rand.NewPCG(13, 2023))
+	patterns := append(randomPatterns(r, 300, 2, 17), randomPatterns(r, 20, 60, 80)...)
+	for _, pattern := range patterns {
+		bp := encodePattern(pattern)
+		for _, targetDiff := range []int{0, 1} {
+			matches := bp.reflections(targetDiff)
+			report := findReflection(pattern, targetDiff)
+			got := 0
+			if len(matches) != 0 {
+				got = candidateScore(matches[0])
+			}
+			if got != report.Score || reflectionWarning(len(matches)) != report.Warning {
+				t.Fatalf("target diff %d: bitmask scored %d (%q), runes scored %d (%q) for %dx%d pattern",
+					targetDiff, got, reflectionWarning(len(matches)), report.Score, report.Warning, len(pattern[0]), len(pattern))
+			}
+		}
+	}
+}
+
+func TestMultipleReflections(t *testing.T) {
+	bp := encodePattern(pattern{[]rune("##"), []rune("##")})
+	matches := bp.reflections(0)
+	if len(matches) != 2 {
+		t.Fatalf("got %d reflections, want 2", len(matches))
+	}
+	if got := reflectionWarning(len(matches)); got != "2 reflections found, scoring the first" {
+		t.Errorf("warning = %q", got)
+	}
+	if got := part1([]bitPattern{bp}); got != 100 {
+		t.Errorf("part1 = %d, want 100", got)
+	}
+}
+
+func TestLargePatternFallsBackToRunes(t *testing.T) {
+	pattern := randomPatterns(rand.New(rand.NewPCG(64, 65)), 1, 65, 100)[0]
+	bp := encodePattern(pattern)
+	if bp.runes == nil {
+		t.Fatalf("%dx%d pattern was encoded as bitmasks", len(pattern[0]), len(pattern))
+	}
+	if got, want := part1([]bitPattern{bp}), findReflectionValue(pattern, 0); got != want {
+		t.Errorf("part1 = %d, want %d", got, want)
+	}
+}
+
+func benchmarkPatterns() []pattern {
+	return randomPatterns(rand.New(rand.NewPCG(13, 2023)), 100, 48, 64)
+}
+
+func BenchmarkRuneReflections(b *testing.B) {
+	patterns := benchmarkPatterns()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, pattern := range patterns {
+			findReflectionValue(pattern, 0)
+			findReflectionValue(pattern, 1)
+		}
+	}
+}
+
+func BenchmarkBitReflections(b *testing.B) {
+	bps := encodePatterns(benchmarkPatterns())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, bp := range bps {
+			bp.reflections(0)
+			bp.reflections(1)
+		}
+	}
+}
@@ -2,28 +2,30 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/csv"
+	"flag"
 	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
-)
 
-const CYCLES = 1000000000
+	"github.com/Shteevee/AoC2023/internal/cycle"
+)
 
 type point struct {
 	x int
 	y int
 }
 
-func copyMap(m map[point]bool) map[point]bool {
-	copy := map[point]bool{}
-	for k, v := range m {
-		copy[k] = v
-	}
-	return copy
-}
-
 func parseRocks(scanner *bufio.Scanner) ([]point, map[point]bool, int, int) {
 	movableRocks := []point{}
 	stationaryRocks := map[point]bool{}
@@ -43,114 +45,321 @@ func parseRocks(scanner *bufio.Scanner) ([]point, map[point]bool, int, int) {
 	return movableRocks, stationaryRocks, x, y
 }
 
-func moveRockNorth(rock point, sRocks map[point]bool) point {
-	for rock.y > 0 && !sRocks[point{x: rock.x, y: rock.y - 1}] {
-		rock.y -= 1
+type platform struct {
+	width  int
+	height int
+	cells  []byte
+}
+
+func newPlatform(mRocks []point, sRocks map[point]bool, maxX int, maxY int) platform {
+	p := platform{width: maxX, height: maxY, cells: bytes.Repeat([]byte{'.'}, maxX*maxY)}
+	for _, rock := range mRocks {
+		p.cells[rock.y*maxX+rock.x] = 'O'
 	}
-	return rock
+	for rock := range sRocks {
+		p.cells[rock.y*maxX+rock.x] = '#'
+	}
+	return p
 }
 
-func moveRockSouth(rock point, sRocks map[point]bool, maxY int) point {
-	for rock.y < maxY-1 && !sRocks[point{x: rock.x, y: rock.y + 1}] {
-		rock.y += 1
+func (p platform) clone() platform {
+	p.cells = slices.Clone(p.cells)
+	return p
+}
+
+// each row or column is a line of cells counted from the edge the rocks roll
+// towards, cell k of a line is at first(line) + k*stride
+func (p platform) lines(dir rune) (int, int, func(line int) int, int, error) {
+	switch dir {
+	case 'N':
+		return p.width, p.height, func(line int) int { return line }, p.width, nil
+	case 'S':
+		return p.width, p.height, func(line int) int { return (p.height-1)*p.width + line }, -p.width, nil
+	case 'W':
+		return p.height, p.width, func(line int) int { return line * p.width }, 1, nil
+	case 'E':
+		return p.height, p.width, func(line int) int { return line*p.width + p.width - 1 }, -1, nil
+	default:
+		return 0, 0, nil, 0, fmt.Errorf("unknown direction %q", dir)
 	}
-	return rock
 }
 
-func moveRockWest(rock point, sRocks map[point]bool) point {
-	for rock.x > 0 && !sRocks[point{x: rock.x - 1, y: rock.y}] {
-		rock.x -= 1
+// counts the rocks between each pair of # and rewrites that stretch with the
+// rocks packed against the edge
+func (p platform) tilt(dir rune) error {
+	lineCount, lineLen, first, stride, err := p.lines(dir)
+	if err != nil {
+		return err
+	}
+	for line := 0; line < lineCount; line++ {
+		i := first(line)
+		segmentStart, rocks := i, 0
+		for k := 0; k <= lineLen; k++ {
+			if k < lineLen && p.cells[i] != '#' {
+				if p.cells[i] == 'O' {
+					rocks++
+				}
+				i += stride
+				continue
+			}
+			for j := segmentStart; j != i; j += stride {
+				if rocks > 0 {
+					p.cells[j] = 'O'
+					rocks--
+				} else {
+					p.cells[j] = '.'
+				}
+			}
+			i += stride
+			segmentStart = i
+		}
 	}
-	return rock
+	return nil
 }
 
-func moveRockEast(rock point, sRocks map[point]bool, maxX int) point {
-	for rock.x < maxX-1 && !sRocks[point{x: rock.x + 1, y: rock.y}] {
-		rock.x += 1
+func (p platform) tiltSequence(sequence string) (platform, error) {
+	p = p.clone()
+	for _, dir := range sequence {
+		if err := p.tilt(dir); err != nil {
+			return p, err
+		}
 	}
-	return rock
+	return p, nil
 }
 
-func part1(mRocks []point, sRocks map[point]bool, maxY int) int {
-	mRocks = moveRocks(mRocks, sRocks, sortNorth, moveRockNorth)
+// each rock adds its distance from the opposite edge, counting the row or
+// column nearest that edge as 1
+func (p platform) load(edge rune) (int, error) {
+	if !strings.ContainsRune("NSWE", edge) {
+		return 0, fmt.Errorf("unknown edge %q", edge)
+	}
+	total := 0
+	for i, c := range p.cells {
+		if c != 'O' {
+			continue
+		}
+		x, y := i%p.width, i/p.width
+		switch edge {
+		case 'N':
+			total += p.height - y
+		case 'S':
+			total += y + 1
+		case 'W':
+			total += p.width - x
+		case 'E':
+			total += x + 1
+		}
+	}
+	return total, nil
+}
+
+func (p platform) northLoad() int {
+	load, _ := p.load('N')
+	return load
+}
 
-	return calcRockLoad(mRocks, maxY)
+func part1(p platform) int {
+	p, _ = p.tiltSequence("N")
+	return p.northLoad()
 }
 
-func moveRocks(
-	mRocks []point,
-	sRocks map[point]bool,
-	sort func(a, b point) int,
-	move func(point, map[point]bool) point,
-) []point {
-	sRocksCopy := copyMap(sRocks)
-	slices.SortFunc[[]point](mRocks, sort)
-	for i := range mRocks {
-		mRocks[i] = move(mRocks[i], sRocksCopy)
-		sRocksCopy[mRocks[i]] = true
+type spinConfig struct {
+	cycles   int
+	sequence string
+	edge     rune
+	useBrent bool
+}
+
+func part2(p platform, config spinConfig) (int, error) {
+	if _, err := p.tiltSequence(config.sequence); err != nil {
+		return 0, err
+	}
+	step := func(p platform) platform {
+		p, _ = p.tiltSequence(config.sequence)
+		return p
 	}
-	return mRocks
+	if config.useBrent {
+		tail, cycleLen := cycle.FindBrent(p, step, func(a, b platform) bool { return bytes.Equal(a.cells, b.cells) })
+		return cycle.StateAt(p, step, config.cycles, tail, cycleLen).load(config.edge)
+	}
+	tail, cycleLen, states := cycle.FindHashed(p, step, func(p platform) string { return string(p.cells) })
+	return states[cycle.EquivalentStep(config.cycles, tail, cycleLen)].load(config.edge)
 }
 
-func sortNorth(a, b point) int { return a.y - b.y }
-func sortWest(a, b point) int  { return a.x - b.x }
-func sortSouth(a, b point) int { return b.y - a.y }
-func sortEast(a, b point) int  { return b.x - a.x }
+func printPlatform(w io.Writer, p platform) error {
+	b := strings.Builder{}
+	for y := 0; y < p.height; y++ {
+		b.Write(p.cells[y*p.width : (y+1)*p.width])
+		b.WriteRune('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
 
-func performCycle(mRocks []point, sRocks map[point]bool, maxX int, maxY int) []point {
-	mRocks = moveRocks(mRocks, sRocks, sortNorth, moveRockNorth)
-	mRocks = moveRocks(mRocks, sRocks, sortWest, moveRockWest)
-	mRocks = moveRocks(mRocks, sRocks, sortSouth, func(p point, m map[point]bool) point { return moveRockSouth(p, m, maxY) })
-	mRocks = moveRocks(mRocks, sRocks, sortEast, func(p point, m map[point]bool) point { return moveRockEast(p, m, maxX) })
-	return mRocks
+// the grid after each spin cycle, with index 0 being before any spins
+func recordHistory(p platform, cycles int, sequence string) []platform {
+	history := []platform{p}
+	for i := 0; i < cycles; i++ {
+		p, _ = p.tiltSequence(sequence)
+		history = append(history, p)
+	}
+	return history
 }
 
-func printRocks(mRocks []point, sRocks map[point]bool, maxX, maxY int) {
-	res := make([][]rune, maxY)
-	for y := range res {
-		for x := 0; x < maxX; x++ {
-			res[y] = append(res[y], '.')
+func writeLoadCSV(w io.Writer, history []platform, edge rune) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write([]string{"cycle", "load", "repeat_of"}); err != nil {
+		return err
+	}
+	seen := map[string]int{}
+	for i, p := range history {
+		repeatOf := ""
+		if first, ok := seen[string(p.cells)]; ok {
+			repeatOf = strconv.Itoa(first)
+		} else {
+			seen[string(p.cells)] = i
+		}
+		load, err := p.load(edge)
+		if err != nil {
+			return err
+		}
+		if err = csvWriter.Write([]string{strconv.Itoa(i), strconv.Itoa(load), repeatOf}); err != nil {
+			return err
 		}
 	}
-	for _, rock := range mRocks {
-		res[rock.y][rock.x] = 'O'
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func replay(w io.Writer, history []platform, edge rune, delay time.Duration) error {
+	for i, p := range history {
+		load, err := p.load(edge)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "\033[H\033[2JCycle %d, load %d\n", i, load); err != nil {
+			return err
+		}
+		if err = printPlatform(w, p); err != nil {
+			return err
+		}
+		time.Sleep(delay)
 	}
-	for rock := range sRocks {
-		res[rock.y][rock.x] = '#'
+	return nil
+}
+
+// creates path and fills it using write, closing the file even when the write fails
+func writeFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
 	}
-	for _, l := range res {
-		fmt.Println(string(l))
+	if err = write(file); err != nil {
+		file.Close()
+		return err
 	}
+	return file.Close()
 }
 
-func calcRockLoad(mRocks []point, maxY int) int {
-	total := 0
-	for _, rock := range mRocks {
-		total += maxY - rock.y
+func writeFrames(dir string, history []platform) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for i, p := range history {
+		err := writeFile(filepath.Join(dir, fmt.Sprintf("cycle_%05d.txt", i)), func(w io.Writer) error {
+			return printPlatform(w, p)
+		})
+		if err != nil {
+			return err
+		}
 	}
-	return total
+	return nil
 }
 
-func part2(mRocks []point, sRocks map[point]bool, maxX int, maxY int) int {
-	cycleCache := map[string]int{}
-	for i := 0; i < CYCLES; i++ {
-		mRocks = performCycle(mRocks, sRocks, maxX, maxY)
-		// this is cycle detection is pretty gross but it works
-		slices.SortStableFunc[[]point](mRocks, sortNorth)
-		if cycleIndex, ok := cycleCache[fmt.Sprint(mRocks)]; ok {
-			cycleDiff := i - cycleIndex
-			if (CYCLES-cycleIndex-1)%cycleDiff == 0 {
-				return calcRockLoad(mRocks, maxY)
+const gifScale = 4
+
+func writeGIF(w io.Writer, history []platform, delay time.Duration) error {
+	palette := color.Palette{
+		color.RGBA{R: 20, G: 20, B: 30, A: 255},
+		color.RGBA{R: 120, G: 120, B: 130, A: 255},
+		color.RGBA{R: 230, G: 190, B: 60, A: 255},
+	}
+	anim := gif.GIF{}
+	for _, p := range history {
+		img := image.NewPaletted(image.Rect(0, 0, p.width*gifScale, p.height*gifScale), palette)
+		for i, c := range p.cells {
+			index := uint8(0)
+			if c == '#' {
+				index = 1
+			} else if c == 'O' {
+				index = 2
+			}
+			x, y := i%p.width, i/p.width
+			for dy := 0; dy < gifScale; dy++ {
+				for dx := 0; dx < gifScale; dx++ {
+					img.SetColorIndex(x*gifScale+dx, y*gifScale+dy, index)
+				}
 			}
-		} else {
-			cycleCache[fmt.Sprint(mRocks)] = i
 		}
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, int(delay/(10*time.Millisecond)))
 	}
+	return gif.EncodeAll(w, &anim)
+}
 
-	return calcRockLoad(mRocks, maxY)
+func writeHistory(
+	history []platform,
+	edge rune,
+	delay time.Duration,
+	csvPath string,
+	framesDir string,
+	gifPath string,
+) error {
+	if csvPath != "" {
+		err := writeFile(csvPath, func(w io.Writer) error { return writeLoadCSV(w, history, edge) })
+		if err != nil {
+			return err
+		}
+	}
+	if framesDir != "" {
+		if err := writeFrames(framesDir, history); err != nil {
+			return err
+		}
+	}
+	if gifPath != "" {
+		err := writeFile(gifPath, func(w io.Writer) error { return writeGIF(w, history, delay) })
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func main() {
+	brentFlag := flag.Bool("brent", false, "use brent's algorithm rather than a hash map for cycle detection")
+	historyFlag := flag.Int("history", 0, "record this many spin cycles for the outputs below, 0 for up to the first repeat")
+	replayFlag := flag.Bool("replay", false, "animate the recorded cycles in the terminal")
+	delayFlag := flag.Duration("delay", 100*time.Millisecond, "time between replay and gif frames")
+	csvFlag := flag.String("csv", "", "write the -edge load of each recorded cycle to this csv file")
+	framesFlag := flag.String("frames", "", "write each recorded cycle as a text file in this directory")
+	gifFlag := flag.String("gif", "", "write the recorded cycles as an animated gif to this file")
+	cyclesFlag := flag.Int("cycles", 1000000000, "number of spin cycles for part 2")
+	sequenceFlag := flag.String("sequence", "NWSE", "tilt directions making up one spin cycle")
+	edgeFlag := flag.String("edge", "N", "edge the part 2 load is measured against")
+	flag.Parse()
+	if *cyclesFlag < 0 {
+		log.Fatalf("cycles should not be negative, got %d", *cyclesFlag)
+	}
+	if len(*edgeFlag) != 1 {
+		log.Fatalf("edge %q should be one of N, S, W or E", *edgeFlag)
+	}
+	config := spinConfig{
+		cycles:   *cyclesFlag,
+		sequence: strings.ToUpper(*sequenceFlag),
+		edge:     rune(strings.ToUpper(*edgeFlag)[0]),
+		useBrent: *brentFlag,
+	}
+
 	start := time.Now()
 	file, err := os.Open("input.txt")
 	if err != nil {
@@ -163,11 +372,39 @@ func main() {
 	}()
 	scanner := bufio.NewScanner(file)
 	movableRocks, stationaryRocks, x, y := parseRocks(scanner)
+	grid := newPlatform(movableRocks, stationaryRocks, x, y)
+	if _, err = grid.tiltSequence(config.sequence); err != nil {
+		log.Fatal(err)
+	}
+
+	if *replayFlag || *csvFlag != "" || *framesFlag != "" || *gifFlag != "" {
+		cycles := *historyFlag
+		if cycles == 0 {
+			step := func(p platform) platform {
+				p, _ = p.tiltSequence(config.sequence)
+				return p
+			}
+			tail, cycleLen, _ := cycle.FindHashed(grid, step, func(p platform) string { return string(p.cells) })
+			cycles = tail + cycleLen
+		}
+		history := recordHistory(grid, cycles, config.sequence)
+		if err = writeHistory(history, config.edge, *delayFlag, *csvFlag, *framesFlag, *gifFlag); err != nil {
+			log.Fatal(err)
+		}
+		if *replayFlag {
+			if err = replay(os.Stdout, history, config.edge, *delayFlag); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
 
-	result1 := part1(movableRocks, stationaryRocks, y)
+	result1 := part1(grid)
 	fmt.Println("Part 1 result:", result1)
 
-	result2 := part2(movableRocks, stationaryRocks, x, y)
+	result2, err := part2(grid, config)
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Println("Part 2 result:", result2)
 
 	log.Printf("Time taken: %s", time.Since(start))
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"image/gif"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the original map based engine, kept to check and time the grid against
+
+func copyMap(m map[point]bool) map[point]bool {
+	copy := map[point]bool{}
+	for k, v := range m {
+		copy[k] = v
+	}
+	return copy
+}
+
+func moveRockNorth(rock point, sRocks map[point]bool) point {
+	for rock.y > 0 && !sRocks[point{x: rock.x, y: rock.y - 1}] {
+		rock.y -= 1
+	}
+	return rock
+}
+
+func moveRockSouth(rock point, sRocks map[point]bool, maxY int) point {
+	for rock.y < maxY-1 && !sRocks[point{x: rock.x, y: rock.y + 1}] {
+		rock.y += 1
+	}
+	return rock
+}
+
+func moveRockWest(rock point, sRocks map[point]bool) point {
+	for rock.x > 0 && !sRocks[point{x: rock.x - 1, y: rock.y}] {
+		rock.x -= 1
+	}
+	return rock
+}
+
+func moveRockEast(rock point, sRocks map[point]bool, maxX int) point {
+	for rock.x < maxX-1 && !sRocks[point{x: rock.x + 1, y: rock.y}] {
+		rock.x += 1
+	}
+	return rock
+}
+
+func moveRocks(
+	mRocks []point,
+	sRocks map[point]bool,
+	sort func(a, b point) int,
+	move func(point, map[point]bool) point,
+) []point {
+	sRocksCopy := copyMap(sRocks)
+	slices.SortFunc[[]point](mRocks, sort)
+	for i := range mRocks {
+		mRocks[i] = move(mRocks[i], sRocksCopy)
+		sRocksCopy[mRocks[i]] = true
+	}
+	return mRocks
+}
+
+func sortNorth(a, b point) int { return a.y - b.y }
+func sortWest(a, b point) int  { return a.x - b.x }
+func sortSouth(a, b point) int { return b.y - a.y }
+func sortEast(a, b point) int  { return b.x - a.x }
+
+func performCycle(mRocks []point, sRocks map[point]bool, maxX int, maxY int) []point {
+	mRocks = moveRocks(mRocks, sRocks, sortNorth, moveRockNorth)
+	mRocks = moveRocks(mRocks, sRocks, sortWest, moveRockWest)
+	mRocks = moveRocks(mRocks, sRocks, sortSouth, func(p point, m map[point]bool) point { return moveRockSouth(p, m, maxY) })
+	mRocks = moveRocks(mRocks, sRocks, sortEast, func(p point, m map[point]bool) point { return moveRockEast(p, m, maxX) })
+	return mRocks
+}
+
+func calcRockLoad(mRocks []point, maxY int) int {
+	total := 0
+	for _, rock := range mRocks {
+		total += maxY - rock.y
+	}
+	return total
+}
+
+func randomRocks(r *rand.Rand, size int) string {
+	b := strings.Builder{}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			b.WriteByte("....O#"[r.IntN(6)])
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func TestGridMatchesMapEngine(t *testing.T) {
+	r := rand.New(rand.NewPCG(14, 2023))
+	for i := 0; i < 50; i++ {
+		mRocks, sRocks, x, y := parseRocks(bufio.NewScanner(strings.NewReader(randomRocks(r, 1+r.IntN(20)))))
+		p := newPlatform(mRocks, sRocks, x, y)
+		rocks := slices.Clone(mRocks)
+		for c := 0; c < 5; c++ {
+			rocks = performCycle(rocks, sRocks, x, y)
+			p, _ = p.tiltSequence("NWSE")
+			if want := newPlatform(rocks, sRocks, x, y); string(p.cells) != string(want.cells) {
+				t.Fatalf("after %d cycles grid and map engines differ", c+1)
+			}
+			if got, want := p.northLoad(), calcRockLoad(rocks, y); got != want {
+				t.Fatalf("after %d cycles grid load %d, map load %d", c+1, got, want)
+			}
+		}
+	}
+}
+
+func benchmarkRocks() ([]point, map[point]bool, int, int) {
+	input := randomRocks(rand.New(rand.NewPCG(14, 2023)), 100)
+	return parseRocks(bufio.NewScanner(strings.NewReader(input)))
+}
+
+func BenchmarkMapCycle(b *testing.B) {
+	mRocks, sRocks, x, y := benchmarkRocks()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mRocks = performCycle(mRocks, sRocks, x, y)
+	}
+}
+
+func BenchmarkGridCycle(b *testing.B) {
+	mRocks, sRocks, x, y := benchmarkRocks()
+	p := newPlatform(mRocks, sRocks, x, y)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p, _ = p.tiltSequence("NWSE")
+	}
+}
+
+func TestWriteGIFDelay(t *testing.T) {
+	mRocks, sRocks, x, y := parseRocks(bufio.NewScanner(strings.NewReader("O.#\n.O.\n")))
+	history := recordHistory(newPlatform(mRocks, sRocks, x, y), 2, "NWSE")
+	b := bytes.Buffer{}
+	if err := writeGIF(&b, history, 250*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Delay) != len(history) {
+		t.Fatalf("got %d frames, want %d", len(anim.Delay), len(history))
+	}
+	for i, d := range anim.Delay {
+		if d != 25 {
+			t.Errorf("frame %d delay = %d, want 25", i, d)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
+	"iter"
 	"log"
 	"os"
 	"slices"
@@ -19,23 +22,38 @@ const (
 type instruction struct {
 	label     string
 	focalLen  int
-	hash      int
 	intsrType int
 }
 
+// the whole file is one comma separated sequence, newlines are ignored
 func parseInstr(scanner *bufio.Scanner) []string {
-	scanner.Scan()
-	return strings.Split(scanner.Text(), ",")
+	b := strings.Builder{}
+	for scanner.Scan() {
+		b.WriteString(strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	return strings.Split(b.String(), ",")
 }
 
-func HASH(s string) int {
-	current := 0
-	for _, c := range s {
-		current = ((current + int(c)) * 17) % 256
+// the result is always from 0 to modulus-1, even with a negative multiplier
+func newHASH(multiplier int, modulus int) (func(string) int, error) {
+	if modulus <= 0 {
+		return nil, fmt.Errorf("HASH modulus should be positive, got %d", modulus)
 	}
-	return current
+	return func(s string) int {
+		current := 0
+		for _, c := range s {
+			current = nonNegativeMod((current+int(c))*multiplier, modulus)
+		}
+		return current
+	}, nil
+}
+
+func nonNegativeMod(a int, b int) int {
+	return (a%b + b) % b
 }
 
+var HASH, _ = newHASH(17, 256)
+
 func part1(instr []string) int {
 	total := 0
 	for _, s := range instr {
@@ -44,71 +62,242 @@ func part1(instr []string) int {
 	return total
 }
 
-func createInstructions(instr []string) []instruction {
+const (
+	MIN_FOCAL_LEN = 1
+	MAX_FOCAL_LEN = 9
+)
+
+type instructionError struct {
+	offset int
+	msg    string
+}
+
+func (e instructionError) Error() string {
+	return fmt.Sprintf("character %d: %s", e.offset, e.msg)
+}
+
+// step  = label ( "=" focal | "-" )
+// label = one or more of a-z
+// focal = a whole number from MIN_FOCAL_LEN to MAX_FOCAL_LEN
+// offset is where s starts in the sequence, so errors point at the bad character
+func parseInstruction(s string, offset int) (instruction, error) {
+	instruction := instruction{}
+	if s == "" {
+		return instruction, instructionError{offset, "empty step"}
+	}
+	labelEnd := strings.IndexFunc(s, func(c rune) bool { return c < 'a' || c > 'z' })
+	if labelEnd == -1 {
+		return instruction, instructionError{offset + len(s), fmt.Sprintf("step %q has no operation, expected = or -", s)}
+	}
+	if labelEnd == 0 {
+		return instruction, instructionError{offset, fmt.Sprintf("step %q should start with a label of a-z", s)}
+	}
+	instruction.label = s[:labelEnd]
+
+	switch s[labelEnd] {
+	case '-':
+		if labelEnd != len(s)-1 {
+			return instruction, instructionError{offset + labelEnd + 1, fmt.Sprintf("unexpected %q after -", s[labelEnd+1:])}
+		}
+		instruction.intsrType = REMOVE
+	case '=':
+		focal := s[labelEnd+1:]
+		focalLen, err := strconv.Atoi(focal)
+		if err != nil || strings.ContainsAny(focal, "+-") {
+			return instruction, instructionError{offset + labelEnd + 1, fmt.Sprintf("focal length %q is not a number", focal)}
+		}
+		if focalLen < MIN_FOCAL_LEN || focalLen > MAX_FOCAL_LEN {
+			return instruction, instructionError{
+				offset + labelEnd + 1,
+				fmt.Sprintf("focal length %d should be from %d to %d", focalLen, MIN_FOCAL_LEN, MAX_FOCAL_LEN),
+			}
+		}
+		instruction.focalLen = focalLen
+		instruction.intsrType = EQUAL
+	default:
+		return instruction, instructionError{offset + labelEnd, fmt.Sprintf("unexpected %q, expected = or -", s[labelEnd])}
+	}
+	return instruction, nil
+}
+
+func createInstructions(instr []string) ([]instruction, error) {
 	instructions := []instruction{}
+	offset := 0
 	for _, s := range instr {
-		instruction := instruction{}
-		if split := strings.Split(s, "="); len(split) == 2 {
-			instruction.label = split[0]
-			instruction.hash = HASH(split[0])
-			instruction.focalLen, _ = strconv.Atoi(split[1])
-			instruction.intsrType = EQUAL
-		} else {
-			split := strings.Split(s, "-")
-			instruction.label = split[0]
-			instruction.hash = HASH(split[0])
-			instruction.intsrType = REMOVE
+		instruction, err := parseInstruction(s, offset)
+		if err != nil {
+			return nil, err
 		}
 		instructions = append(instructions, instruction)
+		offset += len(s) + 1
 	}
 
-	return instructions
+	return instructions, nil
 }
 
-func fillBoxes(instructions []instruction) [][]instruction {
-	boxes := make([][]instruction, 256)
-	for _, instrct := range instructions {
-		switch instrct.intsrType {
-		case EQUAL:
-			replaceIndex := slices.IndexFunc[[]instruction](
-				boxes[instrct.hash],
-				func(i instruction) bool { return i.label == instrct.label },
-			)
-			if replaceIndex == -1 {
-				boxes[instrct.hash] = append(boxes[instrct.hash], instrct)
-			} else {
-				boxes[instrct.hash][replaceIndex] = instrct
-			}
-		case REMOVE:
-			removeIndex := slices.IndexFunc[[]instruction](
-				boxes[instrct.hash],
-				func(i instruction) bool { return i.label == instrct.label },
-			)
-			if removeIndex != -1 {
-				boxes[instrct.hash] = append(
-					boxes[instrct.hash][:removeIndex],
-					boxes[instrct.hash][removeIndex+1:]...,
-				)
+type Lens struct {
+	Label    string
+	FocalLen int
+}
+
+// boxes of lenses that keep insertion order, a lens whose label is already in
+// its box replaces the old one in place
+type LensMap struct {
+	boxes [][]Lens
+	hash  func(string) int
+}
+
+func NewLensMap(boxCount int, hash func(string) int) (*LensMap, error) {
+	if boxCount <= 0 {
+		return nil, fmt.Errorf("box count should be positive, got %d", boxCount)
+	}
+	return &LensMap{boxes: make([][]Lens, boxCount), hash: hash}, nil
+}
+
+// hash may be any function, so negative values are wrapped into range too
+func (m *LensMap) box(label string) int {
+	return nonNegativeMod(m.hash(label), len(m.boxes))
+}
+
+func (m *LensMap) index(box int, label string) int {
+	return slices.IndexFunc(m.boxes[box], func(l Lens) bool { return l.Label == label })
+}
+
+func (m *LensMap) Set(label string, focalLen int) {
+	box := m.box(label)
+	if i := m.index(box, label); i != -1 {
+		m.boxes[box][i].FocalLen = focalLen
+	} else {
+		m.boxes[box] = append(m.boxes[box], Lens{Label: label, FocalLen: focalLen})
+	}
+}
+
+func (m *LensMap) Remove(label string) bool {
+	box := m.box(label)
+	i := m.index(box, label)
+	if i == -1 {
+		return false
+	}
+	m.boxes[box] = slices.Delete(m.boxes[box], i, i+1)
+	return true
+}
+
+func (m *LensMap) Get(label string) (int, bool) {
+	box := m.box(label)
+	if i := m.index(box, label); i != -1 {
+		return m.boxes[box][i].FocalLen, true
+	}
+	return 0, false
+}
+
+// lenses in box order then slot order
+func (m *LensMap) All() iter.Seq2[int, Lens] {
+	return func(yield func(int, Lens) bool) {
+		for boxNum, box := range m.boxes {
+			for _, l := range box {
+				if !yield(boxNum, l) {
+					return
+				}
 			}
 		}
 	}
-	return boxes
 }
 
-func part2(instr []string) int {
-	instructions := createInstructions(instr)
-	boxes := fillBoxes(instructions)
-
+func (m *LensMap) FocusingPower() int {
 	total := 0
-	for boxNum, box := range boxes {
-		for lensNum, lens := range box {
-			total += (boxNum + 1) * (lensNum + 1) * lens.focalLen
+	for boxNum, box := range m.boxes {
+		for slot, l := range box {
+			total += (boxNum + 1) * (slot + 1) * l.FocalLen
 		}
 	}
 	return total
 }
 
+func (m *LensMap) Apply(instrct instruction) {
+	switch instrct.intsrType {
+	case EQUAL:
+		m.Set(instrct.label, instrct.focalLen)
+	case REMOVE:
+		m.Remove(instrct.label)
+	}
+}
+
+type traceFilter struct {
+	labels []string
+	boxes  []int
+}
+
+func parseTraceFilter(labels string, boxes string) (traceFilter, error) {
+	filter := traceFilter{}
+	if labels != "" {
+		filter.labels = strings.Split(labels, ",")
+	}
+	if boxes != "" {
+		for _, s := range strings.Split(boxes, ",") {
+			box, err := strconv.Atoi(s)
+			if err != nil {
+				return filter, fmt.Errorf("bad box number %q", s)
+			}
+			filter.boxes = append(filter.boxes, box)
+		}
+	}
+	return filter, nil
+}
+
+// prints the boxes in the same layout as the puzzle text after each step
+func writeTrace(w io.Writer, step string, instrct instruction, m *LensMap, filter traceFilter) error {
+	if len(filter.labels) > 0 && !slices.Contains(filter.labels, instrct.label) {
+		return nil
+	}
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "After %q:\n", step)
+	lastBox := -1
+	for boxNum, l := range m.All() {
+		if len(filter.boxes) > 0 && !slices.Contains(filter.boxes, boxNum) {
+			continue
+		}
+		if boxNum != lastBox {
+			if lastBox != -1 {
+				b.WriteRune('\n')
+			}
+			fmt.Fprintf(&b, "Box %d:", boxNum)
+			lastBox = boxNum
+		}
+		fmt.Fprintf(&b, " [%s %d]", l.Label, l.FocalLen)
+	}
+	if lastBox != -1 {
+		b.WriteRune('\n')
+	}
+	b.WriteRune('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func part2(instr []string, lensMap *LensMap, onStep func(string, instruction) error) (int, error) {
+	instructions, err := createInstructions(instr)
+	if err != nil {
+		return 0, err
+	}
+	for i, instrct := range instructions {
+		lensMap.Apply(instrct)
+		if onStep != nil {
+			if err := onStep(instr[i], instrct); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return lensMap.FocusingPower(), nil
+}
+
 func main() {
+	boxesFlag := flag.Int("boxes", 256, "number of boxes in the HASHMAP")
+	multiplierFlag := flag.Int("multiplier", 17, "HASH multiplier")
+	moduloFlag := flag.Int("modulo", 256, "HASH modulus")
+	traceFlag := flag.Bool("trace", false, "print the boxes after every step of the initialization sequence")
+	traceLabelsFlag := flag.String("trace-labels", "", "only trace steps for these comma separated labels")
+	traceBoxesFlag := flag.String("trace-boxes", "", "only show these comma separated box numbers when tracing")
+	flag.Parse()
+
 	start := time.Now()
 	file, err := os.Open("input.txt")
 	if err != nil {
@@ -126,7 +315,28 @@ func main() {
 	result1 := part1(instr)
 	fmt.Println("Part 1 result:", result1)
 
-	result2 := part2(instr)
+	hash, err := newHASH(*multiplierFlag, *moduloFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lensMap, err := NewLensMap(*boxesFlag, hash)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var onStep func(string, instruction) error
+	if *traceFlag {
+		filter, err := parseTraceFilter(*traceLabelsFlag, *traceBoxesFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		onStep = func(step string, instrct instruction) error {
+			return writeTrace(os.Stdout, step, instrct, lensMap, filter)
+		}
+	}
+	result2, err := part2(instr, lensMap, onStep)
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Println("Part 2 result:", result2)
 
 	log.Printf("Time taken: %s", time.Since(start))
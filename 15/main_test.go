@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewHASHRejectsBadModulus(t *testing.T) {
+	for _, modulus := range []int{0, -1} {
+		if _, err := newHASH(17, modulus); err == nil {
+			t.Errorf("modulus %d: expected an error", modulus)
+		}
+	}
+}
+
+func TestNewLensMapRejectsBadBoxCount(t *testing.T) {
+	for _, boxes := range []int{0, -3} {
+		if _, err := NewLensMap(boxes, HASH); err == nil {
+			t.Errorf("%d boxes: expected an error", boxes)
+		}
+	}
+}
+
+func TestNegativeHashesStayInRange(t *testing.T) {
+	hash, err := newHASH(-1, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewLensMap(7, func(s string) int { return -hash(s) - 1 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range []string{"rn", "cm", "qp", "pc", "ot", "ab"} {
+		if h := hash(label); h < 0 || h >= 256 {
+			t.Errorf("hash(%q) = %d, want 0 to 255", label, h)
+		}
+		if box := m.box(label); box < 0 || box >= 7 {
+			t.Errorf("box(%q) = %d, want 0 to 6", label, box)
+		}
+		m.Set(label, 1)
+	}
+	if got, ok := m.Get("ot"); !ok || got != 1 {
+		t.Errorf("Get(ot) = %d, %v", got, ok)
+	}
+}
+
+func TestExample(t *testing.T) {
+	instr := exampleSteps
+	if got := part1(instr); got != 1320 {
+		t.Errorf("part1 = %d, want 1320", got)
+	}
+	m, err := NewLensMap(256, HASH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := part2(instr, m, nil); err != nil || got != 145 {
+		t.Errorf("part2 = %d, %v, want 145", got, err)
+	}
+}
+
+func TestParseInstructionErrors(t *testing.T) {
+	tests := []struct {
+		step   string
+		offset int
+		msg    string
+	}{
+		{"ab=x", 3, `focal length "x" is not a number`},
+		{"ab", 2, `step "ab" has no operation, expected = or -`},
+		{"ab=", 3, `focal length "" is not a number`},
+		{"ab=0", 3, "focal length 0 should be from 1 to 9"},
+		{"ab=10", 3, "focal length 10 should be from 1 to 9"},
+		{"=1", 0, `step "=1" should start with a label of a-z`},
+		{"ab-1", 3, `unexpected "1" after -`},
+		{"Ab=1", 0, `step "Ab=1" should start with a label of a-z`},
+	}
+	for _, tt := range tests {
+		// a valid step in front checks offsets are counted across the sequence
+		_, err := createInstructions([]string{"rn=1", tt.step})
+		var instrErr instructionError
+		if !errors.As(err, &instrErr) {
+			t.Errorf("%q: got %v, expected an instructionError", tt.step, err)
+			continue
+		}
+		if instrErr.offset != len("rn=1,")+tt.offset || instrErr.msg != tt.msg {
+			t.Errorf("%q: got offset %d %q, want offset %d %q", tt.step, instrErr.offset, instrErr.msg, len("rn=1,")+tt.offset, tt.msg)
+		}
+	}
+}
+
+func TestParseInstrJoinsLines(t *testing.T) {
+	input := "rn=1,cm-,\r\nqp=3,cm=2\r\n,qp-\r\n"
+	got := parseInstr(bufio.NewScanner(strings.NewReader(input)))
+	want := []string{"rn=1", "cm-", "qp=3", "cm=2", "qp-"}
+	if !slices.Equal(got, want) {
+		t.Errorf("parseInstr = %q, want %q", got, want)
+	}
+	if _, err := createInstructions(got); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAllOrder(t *testing.T) {
+	m, err := NewLensMap(256, HASH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instructions, err := createInstructions(exampleSteps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, instrct := range instructions {
+		m.Apply(instrct)
+	}
+	type boxLens struct {
+		box  int
+		lens Lens
+	}
+	got := []boxLens{}
+	for box, l := range m.All() {
+		got = append(got, boxLens{box, l})
+	}
+	want := []boxLens{{0, Lens{"rn", 1}}, {0, Lens{"cm", 2}}, {3, Lens{"ot", 7}}, {3, Lens{"ab", 5}}, {3, Lens{"pc", 6}}}
+	if !slices.Equal(got, want) {
+		t.Errorf("All = %v, want %v", got, want)
+	}
+}
+
+var exampleSteps = []string{"rn=1", "cm-", "qp=3", "cm=2", "qp-", "pc=4", "ot=9", "ab=5", "pc-", "pc=6", "ot=7"}
+
+// the listing from the puzzle text
+const exampleTrace = `After "rn=1":
+Box 0: [rn 1]
+
+After "cm-":
+Box 0: [rn 1]
+
+After "qp=3":
+Box 0: [rn 1]
+Box 1: [qp 3]
+
+After "cm=2":
+Box 0: [rn 1] [cm 2]
+Box 1: [qp 3]
+
+After "qp-":
+Box 0: [rn 1] [cm 2]
+
+After "pc=4":
+Box 0: [rn 1] [cm 2]
+Box 3: [pc 4]
+
+After "ot=9":
+Box 0: [rn 1] [cm 2]
+Box 3: [pc 4] [ot 9]
+
+After "ab=5":
+Box 0: [rn 1] [cm 2]
+Box 3: [pc 4] [ot 9] [ab 5]
+
+After "pc-":
+Box 0: [rn 1] [cm 2]
+Box 3: [ot 9] [ab 5]
+
+After "pc=6":
+Box 0: [rn 1] [cm 2]
+Box 3: [ot 9] [ab 5] [pc 6]
+
+After "ot=7":
+Box 0: [rn 1] [cm 2]
+Box 3: [ot 7] [ab 5] [pc 6]
+
+`
+
+func trace(t *testing.T, labels string, boxes string) string {
+	t.Helper()
+	filter, err := parseTraceFilter(labels, boxes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewLensMap(256, HASH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := strings.Builder{}
+	_, err = part2(exampleSteps, m, func(step string, instrct instruction) error {
+		return writeTrace(&b, step, instrct, m, filter)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestWriteTrace(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels string
+		boxes  string
+		want   string
+	}{
+		{"unfiltered", "", "", exampleTrace},
+		{
+			"pc in box 3",
+			"pc",
+			"3",
+			"After \"pc=4\":\nBox 3: [pc 4]\n\nAfter \"pc-\":\nBox 3: [ot 9] [ab 5]\n\nAfter \"pc=6\":\nBox 3: [ot 9] [ab 5] [pc 6]\n\n",
+		},
+		{"empty boxes are left out", "qp", "1,3", "After \"qp=3\":\nBox 1: [qp 3]\n\nAfter \"qp-\":\n\n"},
+	}
+	for _, tt := range tests {
+		if got := trace(t, tt.labels, tt.boxes); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseTraceFilterRejectsBadBox(t *testing.T) {
+	if _, err := parseTraceFilter("", "1,x"); err == nil {
+		t.Error("expected an error for box x")
+	}
+}
@@ -2,10 +2,20 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
 	"log"
+	"math/bits"
 	"os"
+	"runtime"
 	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -59,7 +69,8 @@ func filterValidLasers(ls []laser, maxX int, maxY int) []laser {
 	return ls[:n]
 }
 
-func nextLasers(l laser, mirrors [][]rune, encounteredSplitters map[point]bool) []laser {
+// every laser leaving l's tile, without any loop detection
+func stepLaser(l laser, mirrors [][]rune) []laser {
 	newLasers := []laser{}
 	switch mirrors[l.y][l.x] {
 	case '.':
@@ -102,15 +113,11 @@ func nextLasers(l laser, mirrors [][]rune, encounteredSplitters map[point]bool)
 		case 'l':
 			newLasers = append(newLasers, laser{x: l.x - 1, y: l.y, direction: l.direction})
 		case 'u', 'd':
-			p := point{x: l.x, y: l.y}
-			if !encounteredSplitters[p] {
-				newLasers = append(
-					newLasers,
-					laser{x: l.x - 1, y: l.y, direction: 'l'},
-					laser{x: l.x + 1, y: l.y, direction: 'r'},
-				)
-				encounteredSplitters[p] = true
-			}
+			newLasers = append(
+				newLasers,
+				laser{x: l.x - 1, y: l.y, direction: 'l'},
+				laser{x: l.x + 1, y: l.y, direction: 'r'},
+			)
 		}
 	case '|':
 		switch l.direction {
@@ -119,34 +126,44 @@ func nextLasers(l laser, mirrors [][]rune, encounteredSplitters map[point]bool)
 		case 'd':
 			newLasers = append(newLasers, laser{x: l.x, y: l.y + 1, direction: l.direction})
 		case 'l', 'r':
-			p := point{x: l.x, y: l.y}
-			if !encounteredSplitters[p] {
-				newLasers = append(
-					newLasers,
-					laser{x: l.x, y: l.y - 1, direction: 'u'},
-					laser{x: l.x, y: l.y + 1, direction: 'd'},
-				)
-				encounteredSplitters[p] = true
-			}
-
+			newLasers = append(
+				newLasers,
+				laser{x: l.x, y: l.y - 1, direction: 'u'},
+				laser{x: l.x, y: l.y + 1, direction: 'd'},
+			)
 		}
 	}
 
 	return filterValidLasers(newLasers, len(mirrors[0]), len(mirrors))
 }
 
-func part1(start laser, mirrors [][]rune) int {
-	pointSet := map[point]bool{}
-	encounteredSplitters := map[point]bool{}
+// each (tile, direction) state is only followed once, which stops splitters
+// and mirror loops alike
+func traceBeam(start laser, mirrors [][]rune) map[laser]bool {
+	visited := map[laser]bool{start: true}
 	laserQueue := Queue{start}
 	for len(laserQueue) != 0 {
 		l := laserQueue.dequeue()
-		p := point{x: l.x, y: l.y}
-		pointSet[p] = true
-		laserQueue.enqueueSlice(nextLasers(l, mirrors, encounteredSplitters))
+		for _, next := range stepLaser(l, mirrors) {
+			if !visited[next] {
+				visited[next] = true
+				laserQueue.enqueue(next)
+			}
+		}
+	}
+	return visited
+}
+
+func energizedTiles(visited map[laser]bool) map[point]bool {
+	pointSet := map[point]bool{}
+	for l := range visited {
+		pointSet[point{x: l.x, y: l.y}] = true
 	}
+	return pointSet
+}
 
-	return len(pointSet)
+func part1(start laser, mirrors [][]rune) int {
+	return len(energizedTiles(traceBeam(start, mirrors)))
 }
 
 func findEdgeLasers(maxX, maxY int) []laser {
@@ -166,18 +183,259 @@ func findEdgeLasers(maxX, maxY int) []laser {
 	return lasers
 }
 
-func part2(mirrors [][]rune) int {
+type edgeResult struct {
+	start     laser
+	energized int
+}
+
+func bestEdgeResult(results []edgeResult) edgeResult {
+	best := results[0]
+	for _, r := range results[1:] {
+		if r.energized > best.energized {
+			best = r
+		}
+	}
+	return best
+}
+
+func part2(mirrors [][]rune, workers int) edgeResult {
 	edgeLasers := findEdgeLasers(len(mirrors[0]), len(mirrors))
-	scores := []int{}
+	results := make([]edgeResult, len(edgeLasers))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = edgeResult{start: edgeLasers[i], energized: part1(edgeLasers[i], mirrors)}
+			}
+		}()
+	}
+	for i := range edgeLasers {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return bestEdgeResult(results)
+}
 
-	for _, l := range edgeLasers {
-		scores = append(scores, part1(l, mirrors))
+var laserDirections = []rune{'r', 'l', 'u', 'd'}
+
+// tarjan's algorithm over (tile, direction) states, every state in a strongly
+// connected component energizes the same tiles so each component's tiles are
+// worked out once as a bitset and reused by everything that flows into it
+type sccMemo struct {
+	mirrors   [][]rune
+	width     int
+	index     []int
+	lowLink   []int
+	onStack   []bool
+	stack     []int
+	sccOf     []int
+	energized [][]uint64
+	counts    []int
+	visited   int
+}
+
+func newSCCMemo(mirrors [][]rune) *sccMemo {
+	states := len(mirrors) * len(mirrors[0]) * len(laserDirections)
+	m := &sccMemo{
+		mirrors: mirrors,
+		width:   len(mirrors[0]),
+		index:   make([]int, states),
+		lowLink: make([]int, states),
+		onStack: make([]bool, states),
+		sccOf:   make([]int, states),
 	}
+	for i := range m.index {
+		m.index[i] = -1
+	}
+	return m
+}
+
+func (m *sccMemo) state(l laser) int {
+	return (l.y*m.width+l.x)*len(laserDirections) + slices.Index(laserDirections, l.direction)
+}
 
-	return slices.Max(scores)
+func (m *sccMemo) laser(state int) laser {
+	tile := state / len(laserDirections)
+	return laser{x: tile % m.width, y: tile / m.width, direction: laserDirections[state%len(laserDirections)]}
+}
+
+func (m *sccMemo) connect(s int) {
+	m.index[s] = m.visited
+	m.lowLink[s] = m.visited
+	m.visited++
+	m.stack = append(m.stack, s)
+	m.onStack[s] = true
+
+	next := []int{}
+	for _, l := range stepLaser(m.laser(s), m.mirrors) {
+		next = append(next, m.state(l))
+	}
+	for _, t := range next {
+		if m.index[t] == -1 {
+			m.connect(t)
+			m.lowLink[s] = min(m.lowLink[s], m.lowLink[t])
+		} else if m.onStack[t] {
+			m.lowLink[s] = min(m.lowLink[s], m.index[t])
+		}
+	}
+
+	if m.lowLink[s] != m.index[s] {
+		return
+	}
+	scc := len(m.energized)
+	members := []int{}
+	for {
+		top := m.stack[len(m.stack)-1]
+		m.stack = m.stack[:len(m.stack)-1]
+		m.onStack[top] = false
+		m.sccOf[top] = scc
+		members = append(members, top)
+		if top == s {
+			break
+		}
+	}
+
+	// components are finished sinks first, so every successor is already done
+	tiles := make([]uint64, (len(m.mirrors)*m.width+63)/64)
+	for _, member := range members {
+		tile := member / len(laserDirections)
+		tiles[tile/64] |= 1 << (tile % 64)
+		for _, l := range stepLaser(m.laser(member), m.mirrors) {
+			if succ := m.sccOf[m.state(l)]; succ != scc {
+				for i, word := range m.energized[succ] {
+					tiles[i] |= word
+				}
+			}
+		}
+	}
+	count := 0
+	for _, word := range tiles {
+		count += bits.OnesCount64(word)
+	}
+	m.energized = append(m.energized, tiles)
+	m.counts = append(m.counts, count)
+}
+
+func (m *sccMemo) energizedFrom(start laser) int {
+	s := m.state(start)
+	if m.index[s] == -1 {
+		m.connect(s)
+	}
+	return m.counts[m.sccOf[s]]
+}
+
+func part2Memo(mirrors [][]rune) edgeResult {
+	memo := newSCCMemo(mirrors)
+	results := []edgeResult{}
+	for _, l := range findEdgeLasers(len(mirrors[0]), len(mirrors)) {
+		results = append(results, edgeResult{start: l, energized: memo.energizedFrom(l)})
+	}
+	return bestEdgeResult(results)
+}
+
+var beamArrows = map[rune]rune{'r': '>', 'l': '<', 'u': '^', 'd': 'v'}
+
+// like the puzzle text, empty tiles show the beam's direction or how many
+// beams cross them and mirrors are left as they are
+func printBeams(w io.Writer, visited map[laser]bool, mirrors [][]rune) error {
+	b := strings.Builder{}
+	for y := range mirrors {
+		for x, c := range mirrors[y] {
+			if c != '.' {
+				b.WriteRune(c)
+				continue
+			}
+			dirs := []rune{}
+			for _, dir := range laserDirections {
+				if visited[laser{x: x, y: y, direction: dir}] {
+					dirs = append(dirs, dir)
+				}
+			}
+			switch len(dirs) {
+			case 0:
+				b.WriteRune('.')
+			case 1:
+				b.WriteRune(beamArrows[dirs[0]])
+			default:
+				b.WriteString(strconv.Itoa(len(dirs)))
+			}
+		}
+		b.WriteRune('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func printEnergized(w io.Writer, visited map[laser]bool, mirrors [][]rune) error {
+	tiles := energizedTiles(visited)
+	b := strings.Builder{}
+	for y := range mirrors {
+		for x := range mirrors[y] {
+			if tiles[point{x: x, y: y}] {
+				b.WriteRune('#')
+			} else {
+				b.WriteRune('.')
+			}
+		}
+		b.WriteRune('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// how many edge starts energize each tile
+func edgeHeatmap(mirrors [][]rune) [][]int {
+	heat := make([][]int, len(mirrors))
+	for y := range heat {
+		heat[y] = make([]int, len(mirrors[0]))
+	}
+	for _, l := range findEdgeLasers(len(mirrors[0]), len(mirrors)) {
+		for p := range energizedTiles(traceBeam(l, mirrors)) {
+			heat[p.y][p.x]++
+		}
+	}
+	return heat
+}
+
+const heatmapScale = 6
+
+func writeHeatmap(w io.Writer, heat [][]int, mirrors [][]rune) error {
+	hottest := 1
+	for _, row := range heat {
+		hottest = max(hottest, slices.Max(row))
+	}
+	img := image.NewRGBA(image.Rect(0, 0, len(heat[0])*heatmapScale, len(heat)*heatmapScale))
+	for y := range heat {
+		for x, h := range heat[y] {
+			// black through red to yellow
+			t := float64(h) / float64(hottest)
+			c := color.RGBA{R: uint8(255 * min(1, 2*t)), G: uint8(255 * max(0, 2*t-1)), A: 255}
+			for dy := 0; dy < heatmapScale; dy++ {
+				for dx := 0; dx < heatmapScale; dx++ {
+					img.Set(x*heatmapScale+dx, y*heatmapScale+dy, c)
+				}
+			}
+			if mirrors[y][x] != '.' {
+				img.Set(x*heatmapScale+heatmapScale/2, y*heatmapScale+heatmapScale/2, color.White)
+			}
+		}
+	}
+	return png.Encode(w, img)
 }
 
 func main() {
+	workersFlag := flag.Int("workers", runtime.NumCPU(), "number of edge starts to run at once")
+	memoFlag := flag.Bool("memo", false, "share energized tiles between edge starts using strongly connected components")
+	beamsFlag := flag.Bool("beams", false, "draw the part 1 beam directions over the mirrors")
+	energizedFlag := flag.Bool("energized", false, "draw the tiles part 1 energizes")
+	heatmapFlag := flag.String("heatmap", "", "write a png of how many edge starts energize each tile to this file")
+	flag.Parse()
+
 	start := time.Now()
 	file, err := os.Open("input.txt")
 	if err != nil {
@@ -192,11 +450,45 @@ func main() {
 	scanner := bufio.NewScanner(file)
 	mirrors := parseMirrors(scanner)
 
+	if *beamsFlag || *energizedFlag {
+		visited := traceBeam(laser{x: 0, y: 0, direction: 'r'}, mirrors)
+		if *beamsFlag {
+			if err = printBeams(os.Stdout, visited, mirrors); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *energizedFlag {
+			if err = printEnergized(os.Stdout, visited, mirrors); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if *heatmapFlag != "" {
+		heatmapFile, err := os.Create(*heatmapFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = writeHeatmap(heatmapFile, edgeHeatmap(mirrors), mirrors); err != nil {
+			log.Fatal(err)
+		}
+		if err = heatmapFile.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	result1 := part1(laser{x: 0, y: 0, direction: 'r'}, mirrors)
 	fmt.Println("Part 1 result:", result1)
 
-	result2 := part2(mirrors)
-	fmt.Println("Part 2 result:", result2)
+	var result2 edgeResult
+	if *memoFlag {
+		result2 = part2Memo(mirrors)
+	} else {
+		result2 = part2(mirrors, max(1, *workersFlag))
+	}
+	fmt.Printf(
+		"Part 2 result: %d (starting at %d,%d heading %c)\n",
+		result2.energized, result2.start.x, result2.start.y, result2.start.direction,
+	)
 
 	log.Printf("Time taken: %s", time.Since(start))
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+var referenceMoves = map[rune][2]int{
+	'r': {1, 0},
+	'l': {-1, 0},
+	'u': {0, -1},
+	'd': {0, 1},
+}
+
+// the directions a beam leaves each tile in, written out separately from
+// stepLaser so the two can't share a mistake
+var referenceTransitions = map[rune]map[rune]string{
+	'.':  {'r': "r", 'l': "l", 'u': "u", 'd': "d"},
+	'/':  {'r': "u", 'l': "d", 'u': "r", 'd': "l"},
+	'\\': {'r': "d", 'l': "u", 'u': "l", 'd': "r"},
+	'-':  {'r': "r", 'l': "l", 'u': "lr", 'd': "lr"},
+	'|':  {'r': "ud", 'l': "ud", 'u': "u", 'd': "d"},
+}
+
+// deliberately naive, keeps sweeping every state until nothing new is lit
+func referenceEnergized(start laser, mirrors [][]rune) int {
+	lit := map[laser]bool{start: true}
+	for changed := true; changed; {
+		changed = false
+		for l := range lit {
+			for _, dir := range referenceTransitions[mirrors[l.y][l.x]][l.direction] {
+				move := referenceMoves[dir]
+				next := laser{x: l.x + move[0], y: l.y + move[1], direction: dir}
+				if next.y < 0 || next.y >= len(mirrors) || next.x < 0 || next.x >= len(mirrors[0]) {
+					continue
+				}
+				if !lit[next] {
+					lit[next] = true
+					changed = true
+				}
+			}
+		}
+	}
+	tiles := map[point]bool{}
+	for l := range lit {
+		tiles[point{x: l.x, y: l.y}] = true
+	}
+	return len(tiles)
+}
+
+func randomMirrors(r *rand.Rand, tiles []rune, width int, height int) [][]rune {
+	mirrors := make([][]rune, height)
+	for y := range mirrors {
+		for x := 0; x < width; x++ {
+			mirrors[y] = append(mirrors[y], tiles[r.IntN(len(tiles))])
+		}
+	}
+	return mirrors
+}
+
+func gridString(mirrors [][]rune) string {
+	rows := []string{}
+	for _, row := range mirrors {
+		rows = append(rows, string(row))
+	}
+	return strings.Join(rows, "\n")
+}
+
+func checkAgainstReference(t *testing.T, start laser, mirrors [][]rune) {
+	t.Helper()
+	if got, want := part1(start, mirrors), referenceEnergized(start, mirrors); got != want {
+		t.Fatalf("from %v got %d energized, reference got %d\n%s", start, got, want, gridString(mirrors))
+	}
+}
+
+func TestRandomGridsMatchReference(t *testing.T) {
+	r := rand.New(rand.NewPCG(16, 2023))
+	for _, tiles := range []string{"..../\\|-", "/\\"} {
+		for i := 0; i < 200; i++ {
+			mirrors := randomMirrors(r, []rune(tiles), 1+r.IntN(12), 1+r.IntN(12))
+			for _, l := range findEdgeLasers(len(mirrors[0]), len(mirrors)) {
+				checkAgainstReference(t, l, mirrors)
+			}
+			if got, want := part2Memo(mirrors), part2(mirrors, 1); got.energized != want.energized {
+				t.Fatalf("memo got %d energized, plain got %d\n%s", got.energized, want.energized, gridString(mirrors))
+			}
+		}
+	}
+}
+
+// four mirrors that bounce a beam round forever, tracing has to stop anyway
+func TestMirrorLoop(t *testing.T) {
+	mirrors := [][]rune{
+		[]rune("/\\"),
+		[]rune("\\/"),
+	}
+	for _, dir := range laserDirections {
+		for y := range mirrors {
+			for x := range mirrors[y] {
+				checkAgainstReference(t, laser{x: x, y: y, direction: dir}, mirrors)
+			}
+		}
+	}
+	if got := part1(laser{x: 0, y: 0, direction: 'u'}, mirrors); got != 4 {
+		t.Errorf("looping beam energized %d tiles, want 4", got)
+	}
+}
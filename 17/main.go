@@ -3,10 +3,13 @@ package main
 import (
 	"bufio"
 	"container/heap"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,17 +19,16 @@ type point struct {
 }
 
 type qItem struct {
-	pos   point
-	dir   rune
-	dist  int
-	index int
-	prev  *qItem
+	pos      point
+	dir      int
+	dist     int
+	priority int
+	index    int
 }
 
-type neighbor struct {
-	pos   point
-	dir   rune
-	steps int
+type searchState struct {
+	pos point
+	dir int
 }
 
 type PriorityQueue []*qItem
@@ -34,7 +36,7 @@ type PriorityQueue []*qItem
 func (pq PriorityQueue) Len() int { return len(pq) }
 
 func (pq PriorityQueue) Less(i, j int) bool {
-	return pq[i].dist < pq[j].dist
+	return pq[i].priority < pq[j].priority
 }
 
 func (pq PriorityQueue) Swap(i, j int) {
@@ -73,89 +75,166 @@ func parseBlocks(scanner *bufio.Scanner) [][]int {
 	return blocks
 }
 
-func validNeighbor(n neighbor, maxX, maxY int, lastDir rune) bool {
-	return n.pos.x >= 0 && n.pos.x < maxX &&
-		n.pos.y >= 0 && n.pos.y < maxY &&
-		n.dir != lastDir &&
-		!(n.pos.x == 0 && n.pos.y == 0)
+// opposite directions differ only in the lowest bit
+var (
+	moveDirs  = []point{{0, -1}, {0, 1}, {-1, 0}, {1, 0}, {-1, -1}, {1, 1}, {1, -1}, {-1, 1}}
+	moveNames = []string{"N", "S", "W", "E", "NW", "SE", "NE", "SW"}
+)
+
+type stepLimit struct {
+	min int
+	max int
 }
 
-func nextNeighbors(qItem qItem, blocks [][]int, minStep, maxStep int) []neighbor {
-	pos := qItem.pos
-	neighbors := []neighbor{}
-	for i := minStep; i <= maxStep; i++ {
-		neighbors = append(neighbors,
-			[]neighbor{
-				{pos: point{x: pos.x - i, y: pos.y}, dir: 'h', steps: -i},
-				{pos: point{x: pos.x + i, y: pos.y}, dir: 'h', steps: i},
-				{pos: point{x: pos.x, y: pos.y - i}, dir: 'v', steps: -i},
-				{pos: point{x: pos.x, y: pos.y + i}, dir: 'v', steps: i},
-			}...)
+// every move is a straight run within its direction's step limit, after which
+// the crucible has to change direction
+type movementRules struct {
+	start        point
+	end          point
+	diagonal     bool
+	allowReverse bool
+	limits       []stepLimit
+}
+
+func newMovementRules(blocks [][]int, minStep, maxStep int) movementRules {
+	rules := movementRules{end: point{x: len(blocks[0]) - 1, y: len(blocks) - 1}}
+	for range moveDirs {
+		rules.limits = append(rules.limits, stepLimit{min: minStep, max: maxStep})
 	}
+	return rules
+}
 
-	n := 0
-	for _, neighbor := range neighbors {
-		if validNeighbor(neighbor, len(blocks[0]), len(blocks), qItem.dir) {
-			neighbors[n] = neighbor
-			n++
+// overrides step limits from a list like "N=1-3,SE=2-4"
+func (r movementRules) withLimits(s string) (movementRules, error) {
+	r.limits = slices.Clone(r.limits)
+	for _, limit := range strings.Split(s, ",") {
+		name, stepRange, found := strings.Cut(limit, "=")
+		dir := slices.Index(moveNames, strings.ToUpper(name))
+		minS, maxS, foundRange := strings.Cut(stepRange, "-")
+		minStep, errMin := strconv.Atoi(minS)
+		maxStep, errMax := strconv.Atoi(maxS)
+		if !found || dir == -1 || !foundRange || errMin != nil || errMax != nil || minStep < 1 || minStep > maxStep {
+			return r, fmt.Errorf("bad step limit %q, expected something like N=1-3", limit)
 		}
+		r.limits[dir] = stepLimit{min: minStep, max: maxStep}
 	}
-	return neighbors[:n]
+	return r, nil
 }
 
-func calcDist(item *qItem, n neighbor, blocks [][]int) int {
-	curr, next, dist := item.pos, n.pos, item.dist
-	switch n.dir {
-	case 'h':
-		if curr.x > next.x {
-			for i := curr.x - 1; i >= next.x; i-- {
-				dist += blocks[curr.y][i]
-			}
-		} else {
-			for i := curr.x + 1; i <= next.x; i++ {
-				dist += blocks[curr.y][i]
-			}
-		}
-	case 'v':
-		if curr.y > next.y {
-			for i := curr.y - 1; i >= next.y; i-- {
-				dist += blocks[i][curr.x]
-			}
-		} else {
-			for i := curr.y + 1; i <= next.y; i++ {
-				dist += blocks[i][curr.x]
-			}
+func (r movementRules) directions() int {
+	if r.diagonal {
+		return len(moveDirs)
+	}
+	return 4
+}
+
+func parsePoint(s string) (point, error) {
+	xs, ys, found := strings.Cut(s, ",")
+	x, errX := strconv.Atoi(xs)
+	y, errY := strconv.Atoi(ys)
+	if !found || errX != nil || errY != nil {
+		return point{}, fmt.Errorf("bad point %q, expected x,y", s)
+	}
+	return point{x: x, y: y}, nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// never more than the real cost as every block entered costs at least the cheapest block
+func heuristic(blocks [][]int, rules movementRules) func(point) int {
+	minCost := blocks[0][0]
+	for _, row := range blocks {
+		minCost = min(minCost, slices.Min(row))
+	}
+	return func(p point) int {
+		dx, dy := abs(rules.end.x-p.x), abs(rules.end.y-p.y)
+		if rules.diagonal {
+			return max(dx, dy) * minCost
 		}
+		return (dx + dy) * minCost
 	}
-	return dist
 }
 
-func findPath(blocks [][]int, minStep, maxStep int) int {
-	q := &PriorityQueue{&qItem{pos: point{x: 0, y: 0}}}
+// dijkstra, or a* when useAStar is set, returning the heat loss and how many
+// states were expanded
+func findPath(blocks [][]int, rules movementRules, useAStar bool) (int, int) {
+	h := func(point) int { return 0 }
+	if useAStar {
+		h = heuristic(blocks, rules)
+	}
+	inBounds := func(p point) bool {
+		return p.x >= 0 && p.x < len(blocks[0]) && p.y >= 0 && p.y < len(blocks)
+	}
+	if !inBounds(rules.start) || !inBounds(rules.end) {
+		return -1, 0
+	}
+
+	q := &PriorityQueue{&qItem{pos: rules.start, dir: -1, priority: h(rules.start)}}
 	heap.Init(q)
-	seen := map[neighbor]bool{}
-	end := point{x: len(blocks[0]) - 1, y: len(blocks) - 1}
+	best := map[searchState]int{{pos: rules.start, dir: -1}: 0}
+	expanded := 0
 
 	for len(*q) > 0 {
 		u := heap.Pop(q).(*qItem)
-		neighbors := nextNeighbors(*u, blocks, minStep, maxStep)
-		if u.pos == end {
-			return u.dist
+		if u.dist > best[searchState{pos: u.pos, dir: u.dir}] {
+			continue
+		}
+		expanded++
+		if u.pos == rules.end {
+			return u.dist, expanded
 		}
-		for _, n := range neighbors {
-			if seen[n] {
+		for d := 0; d < rules.directions(); d++ {
+			if d == u.dir || (!rules.allowReverse && u.dir != -1 && d == u.dir^1) {
 				continue
 			}
-			seen[n] = true
-			nDist := calcDist(u, n, blocks)
-			heap.Push(q, &qItem{pos: n.pos, dir: n.dir, dist: nDist, prev: u})
+			pos, dist := u.pos, u.dist
+			for i := 1; i <= rules.limits[d].max; i++ {
+				pos = point{x: pos.x + moveDirs[d].x, y: pos.y + moveDirs[d].y}
+				if !inBounds(pos) {
+					break
+				}
+				dist += blocks[pos.y][pos.x]
+				if i < rules.limits[d].min {
+					continue
+				}
+				s := searchState{pos: pos, dir: d}
+				if old, ok := best[s]; ok && old <= dist {
+					continue
+				}
+				best[s] = dist
+				heap.Push(q, &qItem{pos: pos, dir: d, dist: dist, priority: dist + h(pos)})
+			}
 		}
 	}
 
-	return -1
+	return -1, expanded
+}
+
+func solve(blocks [][]int, rules movementRules, useAStar bool, stats bool) int {
+	if stats {
+		dijkstra, dijkstraExpanded := findPath(blocks, rules, false)
+		aStar, aStarExpanded := findPath(blocks, rules, true)
+		log.Printf("Dijkstra: %d expanding %d states, A*: %d expanding %d states", dijkstra, dijkstraExpanded, aStar, aStarExpanded)
+	}
+	result, _ := findPath(blocks, rules, useAStar)
+	return result
 }
 
 func main() {
+	startFlag := flag.String("start", "", "start block as x,y, top left by default")
+	endFlag := flag.String("end", "", "end block as x,y, bottom right by default")
+	diagonalFlag := flag.Bool("diagonal", false, "allow diagonal moves")
+	reverseFlag := flag.Bool("reverse", false, "allow turning back the way the crucible came")
+	limitsFlag := flag.String("limits", "", "per direction step limits for both parts, like N=1-3,S=2-5")
+	aStarFlag := flag.Bool("astar", false, "search with a* instead of dijkstra")
+	statsFlag := flag.Bool("stats", false, "log how many states dijkstra and a* expand")
+	flag.Parse()
+
 	start := time.Now()
 	file, err := os.Open("input.txt")
 	if err != nil {
@@ -170,10 +249,38 @@ func main() {
 	scanner := bufio.NewScanner(file)
 	blocks := parseBlocks(scanner)
 
-	result1 := findPath(blocks, 1, 3)
+	checkBounds := func(name string, p point) {
+		if p.x < 0 || p.x >= len(blocks[0]) || p.y < 0 || p.y >= len(blocks) {
+			log.Fatalf("%s %d,%d is outside the grid, x should be 0-%d and y 0-%d", name, p.x, p.y, len(blocks[0])-1, len(blocks)-1)
+		}
+	}
+	configure := func(rules movementRules) movementRules {
+		rules.diagonal = *diagonalFlag
+		rules.allowReverse = *reverseFlag
+		if *startFlag != "" {
+			if rules.start, err = parsePoint(*startFlag); err != nil {
+				log.Fatal(err)
+			}
+			checkBounds("start", rules.start)
+		}
+		if *endFlag != "" {
+			if rules.end, err = parsePoint(*endFlag); err != nil {
+				log.Fatal(err)
+			}
+			checkBounds("end", rules.end)
+		}
+		if *limitsFlag != "" {
+			if rules, err = rules.withLimits(*limitsFlag); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return rules
+	}
+
+	result1 := solve(blocks, configure(newMovementRules(blocks, 1, 3)), *aStarFlag, *statsFlag)
 	fmt.Println("Part 1 result:", result1)
 
-	result2 := findPath(blocks, 4, 10)
+	result2 := solve(blocks, configure(newMovementRules(blocks, 4, 10)), *aStarFlag, *statsFlag)
 	fmt.Println("Part 2 result:", result2)
 
 	log.Printf("Time taken: %s", time.Since(start))
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+const example = `2413432311323
+3215453535623
+3255245654254
+3446585845452
+4546657867536
+1438598798454
+4457876987766
+3637877979653
+4654967986887
+4564679986453
+1224686865563
+2546548887735
+4322674655533`
+
+func TestExample(t *testing.T) {
+	blocks := parseBlocks(bufio.NewScanner(strings.NewReader(example)))
+	for _, useAStar := range []bool{false, true} {
+		if got, _ := findPath(blocks, newMovementRules(blocks, 1, 3), useAStar); got != 102 {
+			t.Errorf("a* %v: part 1 = %d, want 102", useAStar, got)
+		}
+		if got, _ := findPath(blocks, newMovementRules(blocks, 4, 10), useAStar); got != 94 {
+			t.Errorf("a* %v: part 2 = %d, want 94", useAStar, got)
+		}
+	}
+}
+
+type referenceState struct {
+	pos point
+	dir int
+	run int
+}
+
+// moves one block at a time, tracking how far the current run has gone, and
+// relaxes every state until nothing improves
+func referencePath(blocks [][]int, rules movementRules) int {
+	inBounds := func(p point) bool {
+		return p.x >= 0 && p.x < len(blocks[0]) && p.y >= 0 && p.y < len(blocks)
+	}
+	best := map[referenceState]int{{pos: rules.start, dir: -1}: 0}
+	for changed := true; changed; {
+		changed = false
+		for s, dist := range best {
+			for d := 0; d < rules.directions(); d++ {
+				next := referenceState{dir: d, run: 1}
+				switch {
+				case d == s.dir:
+					if s.run >= rules.limits[d].max {
+						continue
+					}
+					next.run = s.run + 1
+				case s.dir != -1 && s.run < rules.limits[s.dir].min:
+					continue
+				case s.dir != -1 && !rules.allowReverse && moveDirs[d].x == -moveDirs[s.dir].x && moveDirs[d].y == -moveDirs[s.dir].y:
+					continue
+				}
+				next.pos = point{x: s.pos.x + moveDirs[d].x, y: s.pos.y + moveDirs[d].y}
+				if !inBounds(next.pos) {
+					continue
+				}
+				nextDist := dist + blocks[next.pos.y][next.pos.x]
+				if old, ok := best[next]; !ok || nextDist < old {
+					best[next] = nextDist
+					changed = true
+				}
+			}
+		}
+	}
+	result := math.MaxInt
+	for s, dist := range best {
+		if s.pos == rules.end && (s.dir == -1 || s.run >= rules.limits[s.dir].min) {
+			result = min(result, dist)
+		}
+	}
+	if result == math.MaxInt {
+		return -1
+	}
+	return result
+}
+
+func randomBlocks(r *rand.Rand) [][]int {
+	blocks := make([][]int, 1+r.IntN(6))
+	width := 1 + r.IntN(6)
+	for y := range blocks {
+		for x := 0; x < width; x++ {
+			blocks[y] = append(blocks[y], 1+r.IntN(9))
+		}
+	}
+	return blocks
+}
+
+func randomRules(r *rand.Rand, blocks [][]int) movementRules {
+	rules := newMovementRules(blocks, 1+r.IntN(2), 2+r.IntN(3))
+	randomPoint := func() point { return point{x: r.IntN(len(blocks[0])), y: r.IntN(len(blocks))} }
+	if r.IntN(2) == 0 {
+		rules.start, rules.end = randomPoint(), randomPoint()
+	}
+	rules.diagonal = r.IntN(2) == 0
+	rules.allowReverse = r.IntN(2) == 0
+	for d := range rules.limits {
+		if r.IntN(3) == 0 {
+			minStep := 1 + r.IntN(3)
+			rules.limits[d] = stepLimit{min: minStep, max: minStep + r.IntN(3)}
+		}
+	}
+	return rules
+}
+
+func TestRandomGridsMatchReference(t *testing.T) {
+	r := rand.New(rand.NewPCG(17, 2023))
+	for i := 0; i < 500; i++ {
+		blocks := randomBlocks(r)
+		rules := randomRules(r, blocks)
+		want := referencePath(blocks, rules)
+		dijkstra, dijkstraExpanded := findPath(blocks, rules, false)
+		aStar, aStarExpanded := findPath(blocks, rules, true)
+		if dijkstra != want || aStar != want {
+			t.Fatalf("rules %+v on %v: dijkstra %d, a* %d, reference %d", rules, blocks, dijkstra, aStar, want)
+		}
+		if aStarExpanded > dijkstraExpanded {
+			t.Fatalf("rules %+v on %v: a* expanded %d states, dijkstra %d", rules, blocks, aStarExpanded, dijkstraExpanded)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	blocks := parseBlocks(bufio.NewScanner(strings.NewReader("19111\n11191\n99911")))
+	corridor := [][]int{{1, 1, 1, 1}}
+	base := newMovementRules(blocks, 1, 3)
+	limits := func(blocks [][]int, s string) []stepLimit {
+		rules, err := newMovementRules(blocks, 1, 3).withLimits(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rules.limits
+	}
+	tests := []struct {
+		name   string
+		blocks [][]int
+		rules  movementRules
+		want   int
+	}{
+		{"default", blocks, base, 8},
+		{"custom start and end", blocks, movementRules{start: point{x: 4, y: 0}, end: point{x: 0, y: 1}, limits: base.limits}, 5},
+		{"diagonal", blocks, movementRules{end: base.end, diagonal: true, limits: base.limits}, 4},
+		{"per direction limits", blocks, movementRules{end: base.end, limits: limits(blocks, "E=2-2,S=1-1")}, 14},
+		{"start is the end", blocks, movementRules{start: point{x: 2, y: 1}, end: point{x: 2, y: 1}, limits: base.limits}, 0},
+		// three east then two back west is the only way to stop on x=1
+		{"no reverse", corridor, movementRules{end: point{x: 1}, limits: limits(corridor, "E=3-3,W=2-2")}, -1},
+		{"reverse", corridor, movementRules{end: point{x: 1}, allowReverse: true, limits: limits(corridor, "E=3-3,W=2-2")}, 5},
+	}
+	for _, tt := range tests {
+		if ref := referencePath(tt.blocks, tt.rules); ref != tt.want {
+			t.Errorf("%s: reference got %d, want %d", tt.name, ref, tt.want)
+		}
+		for _, useAStar := range []bool{false, true} {
+			if got, _ := findPath(tt.blocks, tt.rules, useAStar); got != tt.want {
+				t.Errorf("%s, a* %v: got %d, want %d", tt.name, useAStar, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestWithLimits(t *testing.T) {
+	blocks := [][]int{{1}}
+	rules, err := newMovementRules(blocks, 1, 3).withLimits("n=2-5,SE=1-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.limits[0] != (stepLimit{min: 2, max: 5}) || rules.limits[5] != (stepLimit{min: 1, max: 1}) {
+		t.Errorf("limits = %v", rules.limits)
+	}
+	for _, s := range []string{"N=0-3", "X=1-2", "N=3-1", "N", "N=1", "N=a-2"} {
+		if _, err := newMovementRules(blocks, 1, 3).withLimits(s); err == nil {
+			t.Errorf("withLimits(%q) should fail", s)
+		}
+	}
+}
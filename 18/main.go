@@ -8,12 +8,9 @@ import (
 	"strconv"
 	"strings"
 	"time"
-)
 
-type point struct {
-	x int
-	y int
-}
+	"github.com/Shteevee/AoC2023/internal/polygon"
+)
 
 type plan struct {
 	dir    rune
@@ -32,7 +29,7 @@ func parseDigPlans(scanner *bufio.Scanner) []plan {
 	return plans
 }
 
-func nextPoint(p point, plan plan) point {
+func nextPoint(p polygon.Point, plan plan) polygon.Point {
 	dx, dy := 0, 0
 	switch plan.dir {
 	case 'R':
@@ -45,15 +42,15 @@ func nextPoint(p point, plan plan) point {
 		dy = 1
 	}
 	for i := 1; i <= plan.dist; i++ {
-		p.x += dx
-		p.y += dy
+		p.X += dx
+		p.Y += dy
 	}
 	return p
 }
 
-func getVertices(plans []plan) []point {
-	vertices := []point{{0, 0}}
-	lastPos := point{0, 0}
+func getVertices(plans []plan) []polygon.Point {
+	vertices := []polygon.Point{{X: 0, Y: 0}}
+	lastPos := polygon.Point{X: 0, Y: 0}
 	for i := 0; i < len(plans)-1; i++ {
 		lastPos = nextPoint(lastPos, plans[i])
 		vertices = append(vertices, lastPos)
@@ -61,29 +58,13 @@ func getVertices(plans []plan) []point {
 	return vertices
 }
 
-// shoelace formula
-func calcArea(vertices []point, perimeter int) int {
-	a, b := 0, 0
-	for i := range vertices {
-		if i == len(vertices)-1 {
-			a += vertices[i].x * vertices[0].y
-			b += vertices[i].y * vertices[0].x
-		} else {
-			a += vertices[i].x * vertices[i+1].y
-			b += vertices[i].y * vertices[i+1].x
-		}
-	}
-
-	return (a-b)/2 + (perimeter / 2) + 1
-}
-
 func part1(plans []plan) int {
 	vertices := getVertices(plans)
 	perimeter := 0
 	for _, plan := range plans {
 		perimeter += plan.dist
 	}
-	return calcArea(vertices, perimeter)
+	return polygon.LatticePoints(vertices, perimeter)
 }
 
 func dirFromColour(s string) rune {
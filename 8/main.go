@@ -2,9 +2,14 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math/big"
 	"os"
+	"slices"
 	"strings"
 	"time"
 )
@@ -28,67 +33,355 @@ func parseMaps(scanner *bufio.Scanner) (string, maps) {
 	return directions, maps
 }
 
-func part1(directions string, maps maps) int {
+func isStart(loc string) bool { return strings.HasSuffix(loc, "A") }
+func isEnd(loc string) bool   { return strings.HasSuffix(loc, "Z") }
+
+func sortedLocations(maps maps) []string {
+	locations := []string{}
+	for loc := range maps {
+		locations = append(locations, loc)
+	}
+	slices.Sort(locations)
+	return locations
+}
+
+func findMissingLocations(maps maps) []string {
+	missing := []string{}
+	for _, loc := range sortedLocations(maps) {
+		for _, dir := range []rune{'L', 'R'} {
+			next := maps[loc][dir]
+			if _, ok := maps[next]; !ok && !slices.Contains(missing, next) {
+				missing = append(missing, next)
+			}
+		}
+	}
+	return missing
+}
+
+func reachableFrom(maps maps, starts []string) map[string]bool {
+	seen := map[string]bool{}
+	queue := slices.Clone(starts)
+	for len(queue) > 0 {
+		loc := queue[0]
+		queue = queue[1:]
+		if _, ok := maps[loc]; !ok || seen[loc] {
+			continue
+		}
+		seen[loc] = true
+		queue = append(queue, maps[loc]['L'], maps[loc]['R'])
+	}
+	return seen
+}
+
+func canReach(maps maps, start string, end func(string) bool) bool {
+	for loc := range reachableFrom(maps, []string{start}) {
+		if end(loc) {
+			return true
+		}
+	}
+	return false
+}
+
+// tarjan's algorithm (https://en.wikipedia.org/wiki/Tarjan%27s_strongly_connected_components_algorithm)
+func findSCCs(maps maps) [][]string {
+	index := map[string]int{}
+	lowLink := map[string]int{}
+	onStack := map[string]bool{}
+	stack := []string{}
+	sccs := [][]string{}
+
+	var connect func(loc string)
+	connect = func(loc string) {
+		index[loc] = len(index)
+		lowLink[loc] = index[loc]
+		stack = append(stack, loc)
+		onStack[loc] = true
+		for _, dir := range []rune{'L', 'R'} {
+			next := maps[loc][dir]
+			if _, ok := maps[next]; !ok {
+				continue
+			}
+			if _, visited := index[next]; !visited {
+				connect(next)
+				lowLink[loc] = min(lowLink[loc], lowLink[next])
+			} else if onStack[next] {
+				lowLink[loc] = min(lowLink[loc], index[next])
+			}
+		}
+		if lowLink[loc] == index[loc] {
+			scc := []string{}
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				scc = append(scc, top)
+				if top == loc {
+					break
+				}
+			}
+			slices.Sort(scc)
+			sccs = append(sccs, scc)
+		}
+	}
+
+	for _, loc := range sortedLocations(maps) {
+		if _, visited := index[loc]; !visited {
+			connect(loc)
+		}
+	}
+	return sccs
+}
+
+func writeDot(w io.Writer, maps maps) error {
+	b := strings.Builder{}
+	b.WriteString("digraph network {\n")
+	for _, loc := range sortedLocations(maps) {
+		if isStart(loc) {
+			fmt.Fprintf(&b, "\t%q [style=filled, fillcolor=palegreen];\n", loc)
+		} else if isEnd(loc) {
+			fmt.Fprintf(&b, "\t%q [style=filled, fillcolor=salmon];\n", loc)
+		}
+	}
+	for _, loc := range sortedLocations(maps) {
+		fmt.Fprintf(&b, "\t%q -> %q [label=\"L\"];\n", loc, maps[loc]['L'])
+		fmt.Fprintf(&b, "\t%q -> %q [label=\"R\"];\n", loc, maps[loc]['R'])
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func analyse(maps maps) {
+	starts := findLocationsEndingWithA(maps)
+	slices.Sort(starts)
+
+	sccs := findSCCs(maps)
+	nonTrivial := 0
+	for _, scc := range sccs {
+		if len(scc) > 1 {
+			nonTrivial++
+		}
+	}
+	fmt.Printf("Strongly connected components: %d (%d with more than one node)\n", len(sccs), nonTrivial)
+	for _, scc := range sccs {
+		if len(scc) > 1 {
+			fmt.Println("  ", strings.Join(scc, " "))
+		}
+	}
+
+	fmt.Println("Missing locations:", findMissingLocations(maps))
+
+	reachable := reachableFrom(maps, starts)
+	unreachable := []string{}
+	for _, loc := range sortedLocations(maps) {
+		if !reachable[loc] {
+			unreachable = append(unreachable, loc)
+		}
+	}
+	fmt.Println("Unreachable from any start:", unreachable)
+
+	deadStarts := []string{}
+	for _, loc := range starts {
+		if !canReach(maps, loc, isEnd) {
+			deadStarts = append(deadStarts, loc)
+		}
+	}
+	fmt.Println("Starts that never reach Z:", deadStarts)
+}
+
+func part1(directions string, maps maps) (int, error) {
+	if _, ok := maps["AAA"]; !ok {
+		return 0, errors.New("no AAA location")
+	}
+	if !canReach(maps, "AAA", func(loc string) bool { return loc == "ZZZ" }) {
+		return 0, errors.New("ZZZ is not reachable from AAA")
+	}
 	steps := 0
 	nextLocation := "AAA"
+	seen := map[ghostState]bool{}
 	for nextLocation != "ZZZ" {
-		nextLocation = maps[nextLocation][rune(directions[steps%len(directions)])]
+		s := ghostState{location: nextLocation, dirIndex: steps % len(directions)}
+		if seen[s] {
+			return 0, fmt.Errorf("directions loop back to %s without reaching ZZZ", nextLocation)
+		}
+		seen[s] = true
+		nextLocation = maps[nextLocation][rune(directions[s.dirIndex])]
 		steps++
 	}
-	return steps
+	return steps, nil
 }
 
 func findLocationsEndingWithA(maps maps) []string {
 	locations := []string{}
 	for loc := range maps {
-		if loc[len(loc)-1] == 'A' {
+		if isStart(loc) {
 			locations = append(locations, loc)
 		}
 	}
 	return locations
 }
 
-func allCyclesFilled(cycles []int) bool {
-	result := true
-	for _, cycle := range cycles {
-		result = result && cycle != 0
-	}
-	return result
+type ghost struct {
+	tail      int
+	cycle     int
+	tailHits  []int
+	cycleHits []int
+}
+
+type ghostState struct {
+	location string
+	dirIndex int
 }
 
-func gcd(a int, b int) int {
-	if b == 0 {
-		return a
+func analyseGhost(directions string, maps maps, location string) ghost {
+	seen := map[ghostState]int{}
+	zSteps := []int{}
+	steps := 0
+	for {
+		s := ghostState{location: location, dirIndex: steps % len(directions)}
+		if firstSeen, ok := seen[s]; ok {
+			g := ghost{tail: firstSeen, cycle: steps - firstSeen}
+			for _, z := range zSteps {
+				if z < firstSeen {
+					g.tailHits = append(g.tailHits, z)
+				} else {
+					g.cycleHits = append(g.cycleHits, z)
+				}
+			}
+			return g
+		}
+		seen[s] = steps
+		if isEnd(location) {
+			zSteps = append(zSteps, steps)
+		}
+		location = maps[location][rune(directions[s.dirIndex])]
+		steps++
 	}
-	return gcd(b, a%b)
 }
 
-func findLCM(xs []int) int {
-	result := xs[0]
-	for i := 1; i < len(xs); i++ {
-		result = (xs[i] * result) / (gcd(xs[i], result))
+func (g ghost) atZ(steps int) bool {
+	if steps < g.tail {
+		return slices.Contains(g.tailHits, steps)
 	}
+	return slices.Contains(g.cycleHits, g.tail+(steps-g.tail)%g.cycle)
+}
 
-	return result
+// generalised chinese remainder theorem for x = a mod m, x = b mod n
+func combineCongruence(a, m, b, n *big.Int) (*big.Int, *big.Int, bool) {
+	p := new(big.Int)
+	g := new(big.Int).GCD(p, nil, m, n)
+	diff := new(big.Int).Sub(b, a)
+	if new(big.Int).Mod(diff, g).Sign() != 0 {
+		return nil, nil, false
+	}
+	nOverG := new(big.Int).Quo(n, g)
+	k := new(big.Int).Quo(diff, g)
+	k.Mul(k, p)
+	k.Mod(k, nOverG)
+	lcm := new(big.Int).Mul(m, nOverG)
+	x := new(big.Int).Mul(m, k)
+	x.Add(x, a)
+	x.Mod(x, lcm)
+	return x, lcm, true
 }
 
-func part2(directions string, maps maps) int {
-	steps := 0
-	nextLocations := findLocationsEndingWithA(maps)
-	cycles := make([]int, len(nextLocations))
-	for !allCyclesFilled(cycles) {
-		for i := range nextLocations {
-			if nextLocations[i][len(nextLocations[i])-1] == 'Z' {
-				cycles[i] = steps
+func part2(directions string, maps maps) (int, error) {
+	if missing := findMissingLocations(maps); len(missing) > 0 {
+		return 0, fmt.Errorf("locations referenced but never defined: %v", missing)
+	}
+	ghosts := []ghost{}
+	for _, loc := range findLocationsEndingWithA(maps) {
+		if !canReach(maps, loc, isEnd) {
+			return 0, fmt.Errorf("%s can never reach a location ending with Z", loc)
+		}
+		ghosts = append(ghosts, analyseGhost(directions, maps, loc))
+	}
+	if len(ghosts) == 0 {
+		return 0, errors.New("no starting locations ending with A")
+	}
+
+	allAtZ := func(steps int) bool {
+		for _, g := range ghosts {
+			if !g.atZ(steps) {
+				return false
 			}
-			nextLocations[i] = maps[nextLocations[i]][rune(directions[steps%len(directions)])]
 		}
-		steps++
+		return true
+	}
+
+	best := -1
+	maxTail := 0
+	for _, g := range ghosts {
+		maxTail = max(maxTail, g.tail)
+		for _, t := range g.tailHits {
+			if allAtZ(t) && (best == -1 || t < best) {
+				best = t
+			}
+		}
+	}
+
+	residues := []*big.Int{big.NewInt(0)}
+	modulus := big.NewInt(1)
+	for _, g := range ghosts {
+		nextResidues := map[string]*big.Int{}
+		var nextModulus *big.Int
+		cycle := big.NewInt(int64(g.cycle))
+		for _, r := range residues {
+			for _, hit := range g.cycleHits {
+				b := big.NewInt(int64(hit % g.cycle))
+				x, lcm, ok := combineCongruence(r, modulus, b, cycle)
+				if ok {
+					nextResidues[x.String()] = x
+					nextModulus = lcm
+				}
+			}
+		}
+		if len(nextResidues) == 0 {
+			residues = nil
+			break
+		}
+		residues = []*big.Int{}
+		for _, x := range nextResidues {
+			residues = append(residues, x)
+		}
+		modulus = nextModulus
+	}
+
+	bigBest := new(big.Int)
+	if best != -1 {
+		bigBest.SetInt64(int64(best))
+	}
+	found := best != -1
+	lowerBound := big.NewInt(int64(maxTail))
+	for _, r := range residues {
+		x := new(big.Int).Set(r)
+		if x.Cmp(lowerBound) < 0 {
+			k := new(big.Int).Sub(lowerBound, x)
+			k.Add(k, modulus)
+			k.Sub(k, big.NewInt(1))
+			k.Quo(k, modulus)
+			x.Add(x, k.Mul(k, modulus))
+		}
+		if !found || x.Cmp(bigBest) < 0 {
+			bigBest = x
+			found = true
+		}
 	}
-	return findLCM(cycles)
+
+	if !found {
+		return 0, errors.New("ghosts are never all on Z nodes at the same time")
+	}
+	if !bigBest.IsInt64() {
+		return 0, fmt.Errorf("result %s overflows int", bigBest)
+	}
+	return int(bigBest.Int64()), nil
 }
 
 func main() {
+	analyseFlag := flag.Bool("analyze", false, "report on the structure of the network instead of solving")
+	dotFlag := flag.String("dot", "", "write the network as graphviz dot to this file")
+	flag.Parse()
+
 	start := time.Now()
 	file, err := os.Open("input.txt")
 	if err != nil {
@@ -103,10 +396,33 @@ func main() {
 	scanner := bufio.NewScanner(file)
 	directions, maps := parseMaps(scanner)
 
-	result1 := part1(directions, maps)
+	if *dotFlag != "" {
+		dotFile, err := os.Create(*dotFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = writeDot(dotFile, maps); err != nil {
+			log.Fatal(err)
+		}
+		if err = dotFile.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *analyseFlag {
+		analyse(maps)
+		return
+	}
+
+	result1, err := part1(directions, maps)
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Println("Part 1 result:", result1)
 
-	result2 := part2(directions, maps)
+	result2, err := part2(directions, maps)
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Println("Part 2 result:", result2)
 
 	log.Printf("Time taken: %s", time.Since(start))
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+// every location steps to the same place whichever way it turns
+func network(next map[string]string) maps {
+	m := maps{}
+	for from, to := range next {
+		m[from] = map[rune]string{'L': to, 'R': to}
+	}
+	return m
+}
+
+// steps every ghost together until they are all on Z, or gives -1 once every
+// combination of ghost states must have been seen
+func bruteForce(directions string, m maps) int {
+	locs := findLocationsEndingWithA(m)
+	limit := 1
+	for range locs {
+		limit *= len(m) * len(directions)
+	}
+	for steps := 0; steps <= limit; steps++ {
+		allAtZ := true
+		for _, loc := range locs {
+			allAtZ = allAtZ && isEnd(loc)
+		}
+		if allAtZ {
+			return steps
+		}
+		for i := range locs {
+			locs[i] = m[locs[i]][rune(directions[steps%len(directions)])]
+		}
+	}
+	return -1
+}
+
+const example = `LR
+
+11A = (11B, XXX)
+11B = (XXX, 11Z)
+11Z = (11B, XXX)
+22A = (22B, XXX)
+22B = (22C, 22C)
+22C = (22Z, 22Z)
+22Z = (22B, 22B)
+XXX = (XXX, XXX)`
+
+func TestPart2(t *testing.T) {
+	directions, exampleMaps := parseMaps(bufio.NewScanner(strings.NewReader(example)))
+	tests := []struct {
+		name       string
+		directions string
+		maps       maps
+		want       int
+	}{
+		{"example", directions, exampleMaps, 6},
+		{
+			// 11 is on Z at 3, 5, 7... after two tail steps, 22 at 1, 4, 7...
+			"non zero tail",
+			"L",
+			network(map[string]string{
+				"11A": "11B", "11B": "11C", "11C": "11Z", "11Z": "11C",
+				"22A": "22Z", "22Z": "22B", "22B": "22A",
+			}),
+			7,
+		},
+		{
+			// 11 is on Z at 1 and 3 mod 5, 22 at 7 mod 8
+			"several Z offsets with coprime cycles",
+			"L",
+			network(map[string]string{
+				"11A": "1BZ", "1BZ": "11C", "11C": "1DZ", "1DZ": "11E", "11E": "11A",
+				"22A": "22B", "22B": "22C", "22C": "22D", "22D": "22E",
+				"22E": "22F", "22F": "22G", "22G": "22Z", "22Z": "22A",
+			}),
+			23,
+		},
+		{
+			// 1 mod 4 and 3 mod 6 only meet at 9 mod 12
+			"non coprime cycles",
+			"L",
+			network(map[string]string{
+				"11A": "11Z", "11Z": "11B", "11B": "11C", "11C": "11A",
+				"22A": "22B", "22B": "22C", "22C": "22Z", "22Z": "22D", "22D": "22E", "22E": "22A",
+			}),
+			9,
+		},
+		{
+			// 11 is only on Z once, before it settles into a cycle without one
+			"answer in the tail",
+			"L",
+			network(map[string]string{
+				"11A": "11Z", "11Z": "11B", "11B": "11C", "11C": "11B",
+				"22A": "22Z", "22Z": "22A",
+			}),
+			1,
+		},
+		{
+			// odd steps for 11 and even steps for 22 never line up
+			"no solution",
+			"L",
+			network(map[string]string{
+				"11A": "11Z", "11Z": "11B", "11B": "11C", "11C": "11A",
+				"22A": "22B", "22B": "22Z", "22Z": "22C", "22C": "22D", "22D": "22E", "22E": "22A",
+			}),
+			-1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if brute := bruteForce(tt.directions, tt.maps); brute != tt.want {
+				t.Fatalf("brute force found %d, table says %d", brute, tt.want)
+			}
+			got, err := part2(tt.directions, tt.maps)
+			if tt.want == -1 {
+				if err == nil {
+					t.Errorf("part2 = %d, expected an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("part2 = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func randomNetwork(r *rand.Rand) (string, maps) {
+	names := []string{}
+	for i := 0; i < 2+r.IntN(7); i++ {
+		names = append(names, string(rune('B'+i))+string(rune('B'+i))+string("ABZ"[r.IntN(3)]))
+	}
+	m := maps{}
+	for _, name := range names {
+		m[name] = map[rune]string{'L': names[r.IntN(len(names))], 'R': names[r.IntN(len(names))]}
+	}
+	directions := []byte{}
+	for i := 0; i < 1+r.IntN(3); i++ {
+		directions = append(directions, "LR"[r.IntN(2)])
+	}
+	return string(directions), m
+}
+
+func TestPart2MatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewPCG(8, 2023))
+	for i := 0; i < 2000; i++ {
+		directions, m := randomNetwork(r)
+		// the brute force bound grows with each ghost, so keep to a few
+		if starts := len(findLocationsEndingWithA(m)); starts == 0 || starts > 3 {
+			continue
+		}
+		want := bruteForce(directions, m)
+		got, err := part2(directions, m)
+		if (want == -1) != (err != nil) || (err == nil && got != want) {
+			t.Fatalf("directions %s network %v: part2 = %d, %v, brute force = %d", directions, m, got, err, want)
+		}
+	}
+}
+
+// 11A only ever reaches itself and 11B, QQQ can't be reached from a start
+// and points at MIS which is never defined
+const brokenNetwork = `L
+
+AAA = (BBB, BBB)
+BBB = (AAA, ZZZ)
+ZZZ = (ZZZ, ZZZ)
+11A = (11B, 11B)
+11B = (11B, 11B)
+QQQ = (ZZZ, MIS)`
+
+func TestBrokenNetwork(t *testing.T) {
+	directions, m := parseMaps(bufio.NewScanner(strings.NewReader(brokenNetwork)))
+
+	sccs := findSCCs(m)
+	want := [][]string{{"11B"}, {"11A"}, {"ZZZ"}, {"AAA", "BBB"}, {"QQQ"}}
+	if fmt.Sprint(sccs) != fmt.Sprint(want) {
+		t.Errorf("findSCCs = %v, want %v", sccs, want)
+	}
+	if missing := findMissingLocations(m); fmt.Sprint(missing) != "[MIS]" {
+		t.Errorf("findMissingLocations = %v, want [MIS]", missing)
+	}
+	if reachableFrom(m, findLocationsEndingWithA(m))["QQQ"] {
+		t.Error("QQQ should be unreachable from every start")
+	}
+	if canReach(m, "11A", isEnd) {
+		t.Error("11A should never reach a Z")
+	}
+
+	// only ever turning left keeps AAA and BBB swapping forever
+	if steps, err := part1(directions, m); err == nil {
+		t.Errorf("part1 = %d, expected an error", steps)
+	}
+	if steps, err := part2(directions, m); err == nil || !strings.Contains(err.Error(), "MIS") {
+		t.Errorf("part2 = %d, %v, expected an error naming MIS", steps, err)
+	}
+	delete(m, "QQQ")
+	if steps, err := part2(directions, m); err == nil || !strings.Contains(err.Error(), "11A") {
+		t.Errorf("part2 = %d, %v, expected an error naming 11A", steps, err)
+	}
+}
+
+func TestPart1Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		maps maps
+	}{
+		{"no AAA", network(map[string]string{"BBB": "ZZZ", "ZZZ": "ZZZ"})},
+		{"ZZZ unreachable", network(map[string]string{"AAA": "BBB", "BBB": "AAA", "ZZZ": "ZZZ"})},
+	}
+	for _, tt := range tests {
+		if steps, err := part1("L", tt.maps); err == nil {
+			t.Errorf("%s: part1 = %d, expected an error", tt.name, steps)
+		}
+	}
+}
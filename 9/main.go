@@ -2,10 +2,11 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"log"
+	"math/big"
 	"os"
-	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -25,37 +26,99 @@ func parseOasisHistories(scanner *bufio.Scanner) [][]int {
 	return histories
 }
 
-func allZeros(xs []int) bool {
+type polynomial struct {
+	degree int
+	length int
+	// forward differences at the first element, newton form coefficients
+	diffs []*big.Int
+	// the differences never reached an all zero row, so the history was only
+	// interpolated at degree length-1 and its predictions are guesses
+	flagged bool
+}
+
+func allZeros(xs []*big.Int) bool {
 	res := true
 	for _, x := range xs {
-		res = res && x == 0
+		res = res && x.Sign() == 0
 	}
 	return res
 }
 
-func nextNumInSeq(hist []int) int {
-	result := hist[len(hist)-1]
-	nextHist := []int{}
-	for !allZeros(hist) {
-		for i := 0; i < len(hist)-1; i++ {
-			nextHist = append(nextHist, hist[i+1]-hist[i])
+func fitPolynomial(hist []int) polynomial {
+	row := []*big.Int{}
+	for _, h := range hist {
+		row = append(row, big.NewInt(int64(h)))
+	}
+	poly := polynomial{length: len(hist)}
+	for len(row) > 0 && !allZeros(row) {
+		poly.diffs = append(poly.diffs, row[0])
+		nextRow := []*big.Int{}
+		for i := 0; i < len(row)-1; i++ {
+			nextRow = append(nextRow, new(big.Int).Sub(row[i+1], row[i]))
+		}
+		row = nextRow
+	}
+	poly.flagged = len(row) == 0
+	poly.degree = len(poly.diffs) - 1
+	return poly
+}
+
+// newton forward form, x is the index relative to the first element of the history
+func (p polynomial) valueAt(x int) *big.Int {
+	result := new(big.Int)
+	binomial := big.NewInt(1)
+	bigX := big.NewInt(int64(x))
+	for j, diff := range p.diffs {
+		if j > 0 {
+			binomial.Mul(binomial, new(big.Int).Sub(bigX, big.NewInt(int64(j-1))))
+			binomial.Quo(binomial, big.NewInt(int64(j)))
 		}
-		result += nextHist[len(nextHist)-1]
-		hist = nextHist
-		nextHist = []int{}
+		result.Add(result, new(big.Int).Mul(binomial, diff))
 	}
 	return result
 }
 
-func part1(histories [][]int) int {
-	total := 0
-	for _, hist := range histories {
-		total += nextNumInSeq(hist)
+func (p polynomial) predictForward(steps int) *big.Int {
+	return p.valueAt(p.length - 1 + steps)
+}
+
+func (p polynomial) predictBackward(steps int) *big.Int {
+	return p.valueAt(-steps)
+}
+
+func fitPolynomials(histories [][]int) []polynomial {
+	polys := []polynomial{}
+	for i, hist := range histories {
+		poly := fitPolynomial(hist)
+		if poly.flagged {
+			log.Printf("history %d never reaches an all zero difference row, fitting it at degree %d", i+1, poly.degree)
+		}
+		polys = append(polys, poly)
+	}
+	return polys
+}
+
+func part1(polys []polynomial, steps int) *big.Int {
+	total := new(big.Int)
+	for _, poly := range polys {
+		total.Add(total, poly.predictForward(steps))
+	}
+	return total
+}
+
+func part2(polys []polynomial, steps int) *big.Int {
+	total := new(big.Int)
+	for _, poly := range polys {
+		total.Add(total, poly.predictBackward(steps))
 	}
 	return total
 }
 
 func main() {
+	stepsFlag := flag.Int("steps", 1, "number of values to extrapolate forwards and backwards")
+	degreesFlag := flag.Bool("degrees", false, "print the detected degree of each history")
+	flag.Parse()
+
 	start := time.Now()
 	file, err := os.Open("input.txt")
 	if err != nil {
@@ -69,14 +132,21 @@ func main() {
 
 	scanner := bufio.NewScanner(file)
 	histories := parseOasisHistories(scanner)
+	polys := fitPolynomials(histories)
+	if *degreesFlag {
+		for i, poly := range polys {
+			if poly.flagged {
+				fmt.Printf("History %d: degree %d (flagged)\n", i+1, poly.degree)
+			} else {
+				fmt.Printf("History %d: degree %d\n", i+1, poly.degree)
+			}
+		}
+	}
 
-	result1 := part1(histories)
+	result1 := part1(polys, *stepsFlag)
 	fmt.Println("Part 1 result:", result1)
 
-	for i := range histories {
-		slices.Reverse(histories[i])
-	}
-	result2 := part1(histories)
+	result2 := part2(polys, *stepsFlag)
 	fmt.Println("Part 2 result:", result2)
 
 	log.Printf("Time taken: %s", time.Since(start))
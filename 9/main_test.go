@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestFitPolynomial(t *testing.T) {
+	tests := []struct {
+		hist     []int
+		degree   int
+		flagged  bool
+		forward  int64
+		backward int64
+	}{
+		{[]int{0, 3, 6, 9, 12, 15}, 1, false, 18, -3},
+		{[]int{1, 3, 6, 10, 15, 21}, 2, false, 28, 0},
+		{[]int{10, 13, 16, 21, 30, 45}, 3, false, 68, 5},
+		// doubling never settles, so the cubic through all four points is used
+		{[]int{1, 2, 4, 8}, 3, true, 15, 0},
+	}
+	for _, tt := range tests {
+		poly := fitPolynomial(tt.hist)
+		if poly.degree != tt.degree || poly.flagged != tt.flagged {
+			t.Errorf("%v: degree %d flagged %v, want degree %d flagged %v", tt.hist, poly.degree, poly.flagged, tt.degree, tt.flagged)
+		}
+		if got := poly.predictForward(1); got.Int64() != tt.forward {
+			t.Errorf("%v: forward %s, want %d", tt.hist, got, tt.forward)
+		}
+		if got := poly.predictBackward(1); got.Int64() != tt.backward {
+			t.Errorf("%v: backward %s, want %d", tt.hist, got, tt.backward)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/Shteevee/AoC2023
+
+go 1.23
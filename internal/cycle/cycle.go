@@ -0,0 +1,69 @@
+// Package cycle finds where a deterministic simulation starts repeating so
+// puzzles asking for the state after a huge number of steps can skip ahead.
+package cycle
+
+// FindBrent uses brent's algorithm (https://en.wikipedia.org/wiki/Cycle_detection#Brent's_algorithm)
+// and returns the tail and cycle length. It only keeps a couple of states so
+// step must be pure, as states get stepped more than once.
+func FindBrent[S any](start S, step func(S) S, equal func(a, b S) bool) (int, int) {
+	power, cycle := 1, 1
+	tortoise := start
+	hare := step(start)
+	for !equal(tortoise, hare) {
+		if power == cycle {
+			tortoise = hare
+			power *= 2
+			cycle = 0
+		}
+		hare = step(hare)
+		cycle++
+	}
+
+	tortoise, hare = start, start
+	for i := 0; i < cycle; i++ {
+		hare = step(hare)
+	}
+	tail := 0
+	for !equal(tortoise, hare) {
+		tortoise = step(tortoise)
+		hare = step(hare)
+		tail++
+	}
+	return tail, cycle
+}
+
+// FindHashed remembers the key of every state until one repeats and returns
+// the tail, the cycle length and every state seen, so any step can be looked
+// up with EquivalentStep without simulating again.
+func FindHashed[S any, K comparable](start S, step func(S) S, key func(S) K) (int, int, []S) {
+	seen := map[K]int{}
+	states := []S{}
+	state := start
+	for {
+		k := key(state)
+		if i, ok := seen[k]; ok {
+			return i, len(states) - i, states
+		}
+		seen[k] = len(states)
+		states = append(states, state)
+		state = step(state)
+	}
+}
+
+// EquivalentStep is the step within the first tail+cycle steps that has the
+// same state as step n.
+func EquivalentStep(n int, tail int, cycle int) int {
+	if n < tail {
+		return n
+	}
+	return tail + (n-tail)%cycle
+}
+
+// StateAt jumps to the state at step n by only simulating up to EquivalentStep.
+func StateAt[S any](start S, step func(S) S, n int, tail int, cycle int) S {
+	state := start
+	for i := 0; i < EquivalentStep(n, tail, cycle); i++ {
+		state = step(state)
+	}
+	return state
+}
@@ -0,0 +1,73 @@
+package cycle
+
+import "testing"
+
+// 0 1 2 3 4 5 6 3 4 5 6 ... has a tail of 3 and a cycle of 4
+func rho(n int) int {
+	if n == 6 {
+		return 3
+	}
+	return n + 1
+}
+
+func TestFindBrent(t *testing.T) {
+	tail, cycle := FindBrent(0, rho, func(a, b int) bool { return a == b })
+	if tail != 3 || cycle != 4 {
+		t.Errorf("got tail %d cycle %d, want tail 3 cycle 4", tail, cycle)
+	}
+}
+
+func TestFindHashed(t *testing.T) {
+	tail, cycle, states := FindHashed(0, rho, func(n int) int { return n })
+	if tail != 3 || cycle != 4 {
+		t.Errorf("got tail %d cycle %d, want tail 3 cycle 4", tail, cycle)
+	}
+	if len(states) != 7 {
+		t.Errorf("got %d states, want 7", len(states))
+	}
+}
+
+func TestNoTail(t *testing.T) {
+	step := func(n int) int { return (n + 1) % 5 }
+	tail, cycle := FindBrent(0, step, func(a, b int) bool { return a == b })
+	if tail != 0 || cycle != 5 {
+		t.Errorf("brent got tail %d cycle %d, want tail 0 cycle 5", tail, cycle)
+	}
+	tail, cycle, _ = FindHashed(0, step, func(n int) int { return n })
+	if tail != 0 || cycle != 5 {
+		t.Errorf("hashed got tail %d cycle %d, want tail 0 cycle 5", tail, cycle)
+	}
+}
+
+func TestEquivalentStep(t *testing.T) {
+	tests := []struct {
+		n    int
+		want int
+	}{
+		{0, 0},
+		{2, 2},
+		{3, 3},
+		{6, 6},
+		{7, 3},
+		{10, 6},
+		{11, 3},
+		{1000000000, 3 + (1000000000-3)%4},
+	}
+	for _, tt := range tests {
+		if got := EquivalentStep(tt.n, 3, 4); got != tt.want {
+			t.Errorf("EquivalentStep(%d, 3, 4) = %d, want %d", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestStateAt(t *testing.T) {
+	for n := 0; n < 50; n++ {
+		want := 0
+		for i := 0; i < n; i++ {
+			want = rho(want)
+		}
+		if got := StateAt(0, rho, n, 3, 4); got != want {
+			t.Errorf("StateAt(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
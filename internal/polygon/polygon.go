@@ -0,0 +1,35 @@
+// Package polygon has the lattice polygon maths shared by the days that walk
+// a closed loop around a grid.
+package polygon
+
+type Point struct {
+	X int
+	Y int
+}
+
+// Area uses the shoelace formula (https://en.wikipedia.org/wiki/Shoelace_formula),
+// the vertices can go round in either direction.
+func Area(vertices []Point) int {
+	a, b := 0, 0
+	for i := range vertices {
+		next := vertices[(i+1)%len(vertices)]
+		a += vertices[i].X * next.Y
+		b += vertices[i].Y * next.X
+	}
+	if a < b {
+		return (b - a) / 2
+	}
+	return (a - b) / 2
+}
+
+// InteriorPoints uses pick's theorem (https://en.wikipedia.org/wiki/Pick%27s_theorem)
+// to count the grid points strictly inside the polygon, boundary is how many
+// grid points lie on its edges.
+func InteriorPoints(vertices []Point, boundary int) int {
+	return Area(vertices) - boundary/2 + 1
+}
+
+// LatticePoints counts the grid points inside the polygon or on its edges.
+func LatticePoints(vertices []Point, boundary int) int {
+	return InteriorPoints(vertices, boundary) + boundary
+}
@@ -0,0 +1,43 @@
+package polygon
+
+import (
+	"slices"
+	"testing"
+)
+
+// 4x3 rectangle, 14 points on the edges and 6 inside
+var rectangle = []Point{{0, 0}, {4, 0}, {4, 3}, {0, 3}}
+
+func TestArea(t *testing.T) {
+	if got := Area(rectangle); got != 12 {
+		t.Errorf("Area = %d, want 12", got)
+	}
+	reversed := slices.Clone(rectangle)
+	slices.Reverse(reversed)
+	if got := Area(reversed); got != 12 {
+		t.Errorf("Area of reversed vertices = %d, want 12", got)
+	}
+}
+
+func TestInteriorPoints(t *testing.T) {
+	if got := InteriorPoints(rectangle, 14); got != 6 {
+		t.Errorf("InteriorPoints = %d, want 6", got)
+	}
+}
+
+func TestLatticePoints(t *testing.T) {
+	if got := LatticePoints(rectangle, 14); got != 20 {
+		t.Errorf("LatticePoints = %d, want 20", got)
+	}
+}
+
+func TestLShape(t *testing.T) {
+	// L shape with 8 units of area, 14 boundary points and 2 inside
+	l := []Point{{0, 0}, {2, 0}, {2, 2}, {4, 2}, {4, 3}, {0, 3}}
+	if got := Area(l); got != 8 {
+		t.Errorf("Area = %d, want 8", got)
+	}
+	if got := InteriorPoints(l, 14); got != 2 {
+		t.Errorf("InteriorPoints = %d, want 2", got)
+	}
+}